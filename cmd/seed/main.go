@@ -0,0 +1,98 @@
+// Command seed fills a development database with demo users and snippets, so that a fresh environment has something
+// to look at. Running it again only adds what is missing. The demo users all log in with the password "pa$$word".
+//
+// It connects to the database in the same way as cmd/web, with the -db-driver and -dsn flags and the DB_PASS
+// variable in .env, and can load a JSON fixtures file instead of the demo data with -fixtures.
+package main
+
+import (
+	"errors"
+	"flag"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/joho/godotenv"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"github.com/rlr524/snippetboxv2/internal/seed"
+	"io/fs"
+	"log"
+	_ "modernc.org/sqlite"
+	"os"
+)
+
+func main() {
+	err := godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal("Error loading .env file")
+	}
+
+	dbDriver := flag.String("db-driver", string(models.MySQL), "Database driver (mysql|postgres|sqlite)")
+	dsn := flag.String("dsn", "", "Data source name (default depends on -db-driver)")
+	snippets := flag.Int("snippets", 30, "Number of demo snippets to create")
+	fixtures := flag.String("fixtures", "", "JSON file of users and snippets to load instead of the demo data")
+	flag.Parse()
+
+	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime)
+
+	dialect := models.Dialect(*dbDriver)
+	if !dialect.Supported() {
+		errorLog.Fatalf("Unsupported database driver %q", *dbDriver)
+	}
+	if *dsn == "" {
+		*dsn = dialect.DefaultDSN(os.Getenv("DB_PASS"))
+	}
+
+	f := seed.Demo(*snippets)
+	if *fixtures != "" {
+		f, err = readFixtures(*fixtures)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+
+	db, err := models.Open(dialect, *dsn)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	defer func(db *models.DB) {
+		_ = db.Close()
+	}(db)
+
+	// As in cmd/web, SQLite databases are migrated straight away and the others must have been migrated already.
+	if dialect == models.SQLite {
+		_, err = db.MigrateUp()
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+	err = db.CheckSchema()
+	if err != nil {
+		errorLog.Fatalf("%v; run cmd/web with -migrate up first", err)
+	}
+
+	loaded, err := seed.Load(seed.Repositories{
+		Users:    &models.UserModel{DB: db},
+		Snippets: &models.SnippetModel{DB: db},
+	}, f)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	infoLog.Printf("Created %d of %d users and %d of %d snippets", loaded.UsersCreated, len(f.Users),
+		loaded.SnippetsCreated, len(f.Snippets))
+}
+
+// readFixtures reads the JSON fixtures file at path.
+func readFixtures(path string) (seed.Fixtures, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return seed.Fixtures{}, err
+	}
+
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	return seed.Decode(file)
+}
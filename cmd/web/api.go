@@ -0,0 +1,542 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxAPIBodyBytes limits the size of JSON request bodies accepted by the API.
+const maxAPIBodyBytes = 1 << 20
+
+// apiSnippet is the JSON representation of a snippet.
+type apiSnippet struct {
+	ID         int    `json:"id"`
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Language   string `json:"language"`
+	Visibility string `json:"visibility"`
+	// Ref is the value used to refer to the snippet in URLs, which is its slug if it is unlisted.
+	Ref              string    `json:"ref"`
+	BurnAfterReading bool      `json:"burn_after_reading"`
+	Created          time.Time `json:"created"`
+	Expires          time.Time `json:"expires"`
+	Author           apiAuthor `json:"author"`
+	Tags             []string  `json:"tags"`
+}
+
+// apiAuthor is the public JSON representation of the user who created a snippet.
+type apiAuthor struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// apiUser is the JSON representation of the authenticated user, as returned by /api/v1/me.
+type apiUser struct {
+	ID      int       `json:"id"`
+	Name    string    `json:"name"`
+	Email   string    `json:"email"`
+	Created time.Time `json:"created"`
+}
+
+// apiCursorMetadata describes one page of a cursor-paginated API listing.
+type apiCursorMetadata struct {
+	Limit        int  `json:"limit"`
+	TotalRecords int  `json:"total_records"`
+	NextCursor   *int `json:"next_cursor"`
+}
+
+// apiErrorResponse is the body of every API error response. Fields mirrors validator.Validator.FieldErrors, and
+// is only present for 422 Unprocessable Entity responses.
+type apiErrorResponse struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+func newAPISnippet(s *models.Snippet) apiSnippet {
+	return apiSnippet{
+		ID:               s.ID,
+		Title:            s.Title,
+		Content:          s.Content,
+		Language:         s.Language,
+		Visibility:       s.Visibility,
+		Ref:              s.Ref(),
+		BurnAfterReading: s.BurnAfterReading,
+		Created:          s.Created,
+		Expires:          s.Expires,
+		Author:           apiAuthor{ID: s.CreatedBy.ID, Name: s.CreatedBy.Name},
+		// Always return an array, rather than null, for snippets without tags.
+		Tags: append([]string{}, s.Tags...),
+	}
+}
+
+// The writeJSON helper encodes data as JSON and writes it with the given status code. Like render, it encodes to a
+// buffer first so that an encoding error can still be reported as a 500.
+func (app *Application) writeJSON(w http.ResponseWriter, status int, data any) {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(js, '\n'))
+}
+
+// The apiError helper sends a JSON error response with the given status code and message.
+func (app *Application) apiError(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, apiErrorResponse{Error: message})
+}
+
+// The apiServerError helper logs the error and stack trace like serverError, but responds with a JSON body.
+func (app *Application) apiServerError(w http.ResponseWriter, err error) {
+	app.logError(err)
+	app.apiError(w, http.StatusInternalServerError, "the server encountered a problem and could not process "+
+		"your request")
+}
+
+// The readJSON helper decodes a JSON request body into dst. Only a single JSON object with known fields is accepted,
+// and the request must be sent with an application/json content type. Requiring that content type also protects
+// cookie-authenticated API requests from CSRF, as browsers can't send it cross-origin without a CORS preflight.
+func (app *Application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errors.New("the request body must be sent with a Content-Type of application/json")
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var typeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError), errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("the request body contains badly-formed JSON")
+		case errors.As(err, &typeError):
+			return fmt.Errorf("the request body contains the wrong type for the %q field", typeError.Field)
+		case errors.Is(err, io.EOF):
+			return errors.New("the request body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("the request body contains unknown field %s",
+				strings.TrimPrefix(err.Error(), "json: unknown field "))
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("the request body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return err
+		}
+	}
+
+	// Make sure there is nothing after the first JSON value.
+	if dec.More() {
+		return errors.New("the request body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// The apiSnippetFromParams helper is the API equivalent of snippetFromParams, sending a JSON 404 and returning false
+// if the snippet named by the :id route parameter doesn't exist or the current user isn't allowed to see it.
+func (app *Application) apiSnippetFromParams(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	snippet, err := app.lookupSnippet(r, params.ByName("id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusNotFound, "the requested snippet could not be found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// The apiOwnedSnippet helper loads the snippet named by the :id route parameter and checks that it belongs to the
+// authenticated user, sending a JSON 404 or 403 and returning false if not.
+func (app *Application) apiOwnedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.apiSnippetFromParams(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if snippet.CreatedBy.ID != app.authenticatedUser(r).ID {
+		app.apiError(w, http.StatusForbidden, "you do not have permission to change this snippet")
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// The requireAPIAuthentication middleware is the API equivalent of requireAuthentication. Rather than redirecting
+// to the login page it sends a 401 Unauthorized JSON response.
+func (app *Application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.apiError(w, http.StatusUnauthorized, "you must be authenticated to access this resource")
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The authenticateToken middleware authenticates API requests which carry an "Authorization: Bearer <token>"
+// header. A valid token replaces any session-based authentication for the request; an invalid or expired one is
+// rejected with a 401 rather than falling back to the session, so clients find out their token no longer works.
+func (app *Application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Let caches know that the response depends on the Authorization header.
+		w.Header().Add("Vary", "Authorization")
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, plaintext, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || plaintext == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.apiError(w, http.StatusUnauthorized, "the Authorization header must be of the form Bearer <token>")
+			return
+		}
+
+		token, err := app.tokens.Authenticate(plaintext)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidToken) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				app.apiError(w, http.StatusUnauthorized, "invalid or expired API token")
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+
+		// Deleted and deactivated users can't use their tokens, just as they can't use their sessions.
+		user, err := app.users.Get(token.UserID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.apiServerError(w, err)
+			return
+		}
+		if user == nil || user.Active != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.apiError(w, http.StatusUnauthorized, "invalid or expired API token")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserContextKey, user)
+		ctx = context.WithValue(ctx, apiTokenContextKey, token)
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The requireWriteScope middleware rejects requests authenticated with a read-only API token. It must come after
+// requireAPIAuthentication in the chain. Requests authenticated with a session cookie are always allowed.
+func (app *Application) requireWriteScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(apiTokenContextKey).(*models.Token)
+		if ok && !token.CanWrite() {
+			app.apiError(w, http.StatusForbidden, "this API token is read-only")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+/*
+description: List the latest active snippets. Results are paginated with a cursor: pass the next_cursor from the
+previous response as the after query string parameter to get the next page. The page size is set with the limit
+parameter.
+route: /api/v1/snippets
+method: GET
+*/
+func (app *Application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	after, err := readInt(r, "after", 0, 1)
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, err := readInt(r, "limit", app.cfg.pageSize, 1)
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit = models.ClampPageSize(limit)
+
+	snippets, more, err := app.snippets.GetLatestAfter(after, limit)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	err = app.tags.Attach(snippets...)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	total, err := app.snippets.CountLive()
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	// Always return an array, even when there are no snippets, rather than null.
+	out := make([]apiSnippet, 0, len(snippets))
+	for _, s := range snippets {
+		out = append(out, newAPISnippet(s))
+	}
+
+	// The cursor is the ID of the last snippet returned, and is null when there are no more snippets.
+	metadata := apiCursorMetadata{Limit: limit, TotalRecords: total}
+	if more {
+		next := snippets[len(snippets)-1].ID
+		metadata.NextCursor = &next
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippets": out, "metadata": metadata})
+}
+
+/*
+description: Get a single snippet. A burn after reading snippet isn't sent, as a retry, prefetch or caching proxy
+would burn it; a 409 Conflict is sent until it is read with POST /api/v1/snippets/:id/read, and a 410 Gone after.
+route: /api/v1/snippets/:id
+method: GET
+*/
+func (app *Application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiSnippetFromParams(w, r)
+	if !ok {
+		return
+	}
+
+	if snippet.BurnAfterReading {
+		if snippet.ReadAt.Valid {
+			app.apiError(w, http.StatusGone, "the requested snippet has already been read")
+		} else {
+			app.apiError(w, http.StatusConflict,
+				"the requested snippet is burnt after reading; POST to /api/v1/snippets/"+snippet.Ref()+
+					"/read to read it")
+		}
+		return
+	}
+
+	err := app.tags.Attach(snippet)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": newAPISnippet(snippet)})
+}
+
+/*
+description: Read a single snippet, burning it if it is burnt after reading, so that it can only be read once; after
+that a 410 Gone is sent. This is the API equivalent of confirming on the HTML view.
+route: /api/v1/snippets/:id/read
+method: POST
+*/
+func (app *Application) apiSnippetRead(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiSnippetFromParams(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	// The snippet was loaded before it was consumed, so if this request is the one which consumes it the content can
+	// still be sent.
+	if snippet.BurnAfterReading {
+		err := app.snippets.Consume(snippet.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrAlreadyRead):
+				app.apiError(w, http.StatusGone, "the requested snippet has already been read")
+			case errors.Is(err, models.ErrNoRecord):
+				app.apiError(w, http.StatusNotFound, "the requested snippet could not be found")
+			default:
+				app.apiServerError(w, err)
+			}
+			return
+		}
+	}
+
+	err := app.tags.Attach(snippet)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": newAPISnippet(snippet)})
+}
+
+/*
+description: Create a snippet from a JSON body of the form {"title": "", "content": "", "language": "",
+"expires": "7d", "tags": [], "visibility": "public", "burn_after_reading": false}. The expiry is a lifetime such as
+"30m", "7d", "1y" or "never", and defaults to the one pre-selected on the create form. The language is detected from
+the content if it is left blank, and the visibility defaults to public.
+route: /api/v1/snippets
+method: POST
+*/
+func (app *Application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	// Fields missing from the body keep the values they have here.
+	form := snippetCreateForm{Expires: app.cfg.expiry.Default(), Visibility: models.VisibilityPublic}
+
+	err := app.readJSON(w, r, &form)
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form.validate(app.cfg.expiry, nil)
+
+	if !form.Valid() {
+		app.writeJSON(w, http.StatusUnprocessableEntity, apiErrorResponse{
+			Error:  "the snippet failed validation",
+			Fields: form.FieldErrors,
+		})
+		return
+	}
+
+	user := app.authenticatedUser(r)
+
+	id, err := app.snippets.Insert(form.Title, form.Content, form.Language, form.Visibility, form.expiresAt, user.ID,
+		form.BurnAfterReading, form.Tags)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	err = app.tags.Attach(snippet)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/snippets/"+snippet.Ref())
+	app.writeJSON(w, http.StatusCreated, map[string]any{"snippet": newAPISnippet(snippet)})
+}
+
+/*
+description: Replace the title, content, expiry and tags of a snippet owned by the current user. The expiry and
+visibility are left unchanged if they are missing from the body.
+route: /api/v1/snippets/:id
+method: PUT
+*/
+func (app *Application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	form := snippetCreateForm{Visibility: snippet.Visibility}
+
+	err := app.readJSON(w, r, &form)
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form.validate(app.cfg.expiry, snippet)
+
+	if !form.Valid() {
+		app.writeJSON(w, http.StatusUnprocessableEntity, apiErrorResponse{
+			Error:  "the snippet failed validation",
+			Fields: form.FieldErrors,
+		})
+		return
+	}
+
+	err = app.snippets.Update(snippet.ID, form.Title, form.Content, form.Language, form.Visibility,
+		form.expiresAt, form.Tags, app.authenticatedUser(r).ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusNotFound, "the requested snippet could not be found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	snippet, err = app.snippets.Get(snippet.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	err = app.tags.Attach(snippet)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": newAPISnippet(snippet)})
+}
+
+/*
+description: Delete a snippet owned by the current user
+route: /api/v1/snippets/:id
+method: DELETE
+*/
+func (app *Application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusNotFound, "the requested snippet could not be found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+description: Get the current user
+route: /api/v1/me
+method: GET
+*/
+func (app *Application) apiMe(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"user": apiUser{
+		ID:      user.ID,
+		Name:    user.Name,
+		Email:   user.Email,
+		Created: user.Created,
+	}})
+}
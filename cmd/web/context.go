@@ -0,0 +1,16 @@
+package main
+
+// contextKey is a custom type for request context keys, which avoids collisions with keys set by
+// any third-party packages that also store values in the request context.
+type contextKey string
+
+const (
+	// isAuthenticatedContextKey stores whether the request comes from an authenticated, active user.
+	isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+	// authenticatedUserContextKey stores the *models.User for the authenticated user.
+	authenticatedUserContextKey = contextKey("authenticatedUser")
+
+	// apiTokenContextKey stores the *models.Token used to authenticate an API request, if there was one.
+	apiTokenContextKey = contextKey("apiToken")
+)
@@ -4,19 +4,97 @@ import (
 	"errors"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
+	"github.com/rlr524/snippetboxv2/internal/diff"
+	"github.com/rlr524/snippetboxv2/internal/expiry"
+	"github.com/rlr524/snippetboxv2/internal/highlight"
 	"github.com/rlr524/snippetboxv2/internal/models"
 	"github.com/rlr524/snippetboxv2/internal/validator"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// The snippetCreateForm is also used to decode JSON request bodies in the API, hence the json tags.
 type snippetCreateForm struct {
-	Title   string `form:"title"`
-	Content string `form:"content"`
-	Expires int    `form:"expires"`
+	Title   string `form:"title" json:"title"`
+	Content string `form:"content" json:"content"`
+	// Language is left blank to have it detected from the content.
+	Language string `form:"language" json:"language"`
+	// Expires is a lifetime such as "7d" or "never" (see the expiry package), customExpiry to use ExpiresCustom
+	// instead, or blank to keep the current expiry when editing.
+	Expires       string   `form:"expires" json:"expires"`
+	ExpiresCustom string   `form:"expires_custom" json:"-"`
+	Tags          []string `form:"tags" json:"tags"`
+	// Visibility is one of models.VisibilityPublic, models.VisibilityUnlisted or models.VisibilityPrivate.
+	Visibility string `form:"visibility" json:"visibility"`
+	// BurnAfterReading is only used when creating a snippet; it can't be changed afterwards.
+	BurnAfterReading bool `form:"burn_after_reading" json:"burn_after_reading"`
 	// Embed the Validator struct
-	validator.Validator `form:"-"`
+	validator.Validator `form:"-" json:"-"`
+
+	// expiresAt is the expiry time worked out from Expires by validate.
+	expiresAt time.Time
+}
+
+// customExpiry is the value of the expires radio button which uses the lifetime typed into the expires_custom field.
+const customExpiry = "custom"
+
+// validate normalizes the tags, runs the checks shared by the create and edit snippet forms, works out the expiry
+// time allowed by the policy, and detects the language if it was left blank. current is the snippet being edited, or
+// nil when creating one; a blank expiry keeps the current snippet's expiry time.
+func (form *snippetCreateForm) validate(policy expiry.Policy, current *models.Snippet) {
+	form.Tags = validator.NormalizeTags(form.Tags)
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title",
+		"This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.checkExpires(policy, current)
+	form.CheckField(validator.MaxItems(form.Tags, validator.MaxTags), "tags",
+		fmt.Sprintf("A snippet cannot have more than %d tags", validator.MaxTags))
+	form.CheckField(validator.AllMatch(form.Tags, validator.TagRX), "tags",
+		"Tags can only contain letters, digits and the characters + # . - and must be at most 30 characters long")
+	form.CheckField(form.Language == "" || highlight.Supported(form.Language), "language",
+		"This field must be a supported language")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityUnlisted,
+		models.VisibilityPrivate), "visibility", "This field must be equal to public, unlisted or private")
+
+	// Only detect the language once everything else is valid, so that a form which is redisplayed with errors
+	// still shows the language the user chose, including "Auto-detect".
+	if form.Valid() && form.Language == "" {
+		form.Language = highlight.Detect(form.Content)
+	}
+}
+
+// checkExpires validates the Expires field against the policy and sets expiresAt.
+func (form *snippetCreateForm) checkExpires(policy expiry.Policy, current *models.Snippet) {
+	lifetime := form.Expires
+	if lifetime == customExpiry {
+		lifetime = form.ExpiresCustom
+	}
+
+	if lifetime == "" {
+		if current == nil {
+			form.AddFieldsError("expires", "This field cannot be blank")
+			return
+		}
+		form.expiresAt = current.Expires
+		return
+	}
+
+	d, never, err := policy.Check(lifetime)
+	if err != nil {
+		form.AddFieldsError("expires", err.Error())
+		return
+	}
+
+	if never {
+		form.expiresAt = models.NeverExpires
+	} else {
+		form.expiresAt = time.Now().Add(d)
+	}
 }
 
 type userSignUpForm struct {
@@ -26,6 +104,18 @@ type userSignUpForm struct {
 	validator.Validator `form:"-"`
 }
 
+type snippetExtendForm struct {
+	Expires             string `form:"expires"`
+	validator.Validator `form:"-"`
+}
+
+type tokenCreateForm struct {
+	Name                string `form:"name"`
+	Scope               string `form:"scope"`
+	Expires             int    `form:"expires"`
+	validator.Validator `form:"-"`
+}
+
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
@@ -33,59 +123,144 @@ type userLoginForm struct {
 }
 
 /*
-description: View all active snippets
+description: View all active snippets, one page at a time using the page query string parameter
 route: /
 method: GET
 */
 func (app *Application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.GetLatest()
+	page, err := readInt(r, "page", 1, 1)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.GetLatest(page, app.cfg.pageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.tags.Attach(snippets...)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
 	// Use the newTemplateData() helper to get a TemplateData struct containing the "default" data and
-	// add the snippets slice to it.
+	// add the snippets slice and the pagination metadata to it.
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
+	data.Metadata = metadata
 
 	app.render(w, http.StatusOK, "home.go.html", data)
 }
 
 /*
-description: View a single snippet
+description: View a single snippet. Burn after reading snippets aren't shown; instead a confirmation page is shown
+which posts back to this route, so that link previews and other bots which only send GET requests can't read them.
 route: /snippet/view/:id
 method: GET
 */
 func (app *Application) snippetView(w http.ResponseWriter, r *http.Request) {
-	// When httprouter is parsing a request, the values of any named parameters will be stored in the request context.
-	params := httprouter.ParamsFromContext(r.Context())
+	// Retrieve the snippet named by the :id parameter, which is either its ID or, for unlisted snippets, its slug.
+	// If no matching record is found, or the current user isn't allowed to see it, a 404 Not Found response is sent.
+	snippet, ok := app.loadSnippet(w, r)
+	if !ok {
+		return
+	}
 
-	// Then use the ByName() method and get the value of the parameter named "id" from the slice and validate.
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < 1 {
-		app.notFound(w)
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	if snippet.BurnAfterReading {
+		w.Header().Set("Cache-Control", "no-store")
+		if snippet.ReadAt.Valid {
+			app.render(w, http.StatusGone, "read.go.html", data)
+			return
+		}
+		app.render(w, http.StatusOK, "burn.go.html", data)
 		return
 	}
 
-	// Use the SnippetModel's Get method to retrieve the data for a specific record based on its ID. If no
-	// matching record is found, return a 404 Not Found response.
-	snippet, err := app.snippets.Get(id)
+	app.render(w, http.StatusOK, "view.go.html", data)
+}
+
+/*
+description: Read a burn after reading snippet, which is destroyed as it is shown. Only one request can ever read
+it; any others are shown the page saying it has already been read.
+route: /snippet/view/:id
+method: POST
+*/
+func (app *Application) snippetViewPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// There is nothing to confirm for other snippets, so send the user to the normal view.
+	if !snippet.BurnAfterReading {
+		http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	// The snippet was loaded before it was consumed, so if this request is the one which consumes it the content can
+	// still be shown.
+	err := app.snippets.Consume(snippet.ID)
 	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
+		switch {
+		case errors.Is(err, models.ErrAlreadyRead):
+			app.render(w, http.StatusGone, "read.go.html", data)
+		case errors.Is(err, models.ErrNoRecord):
 			app.notFound(w)
-		} else {
+		default:
 			app.serverError(w, err)
 		}
 		return
 	}
 
-	data := app.newTemplateData(r)
-	data.Snippet = snippet
-
 	app.render(w, http.StatusOK, "view.go.html", data)
 }
 
+/*
+description: View the content of a single snippet as plain text, for use with tools like curl. Supports ETag
+validation with If-None-Match, and Range requests.
+route: /snippet/raw/:id
+method: GET
+*/
+func (app *Application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromParams(w, r)
+	if !ok {
+		return
+	}
+
+	app.serveSnippetContent(w, r, snippet)
+}
+
+/*
+description: Download the content of a single snippet as a file named after its title and language. Supports the
+same conditional and Range requests as /snippet/raw/:id.
+route: /snippet/download/:id
+method: GET
+*/
+func (app *Application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromParams(w, r)
+	if !ok {
+		return
+	}
+
+	filename := snippetFilename(snippet)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": filename,
+	}))
+
+	app.serveSnippetContent(w, r, snippet)
+}
+
 /*
 description: View the create a snippet form
 route: /snippet/create
@@ -95,7 +270,8 @@ func (app *Application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Expires:    app.cfg.expiry.Default(),
+		Visibility: models.VisibilityPublic,
 	}
 
 	app.render(w, http.StatusOK, "create.go.html", data)
@@ -116,13 +292,7 @@ func (app *Application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Validators
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title",
-		"This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedInt(form.Expires, 1, 7, 365), "expires",
-		"This field must be equal to 1, 7, or 365")
+	form.validate(app.cfg.expiry, nil)
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
@@ -131,7 +301,13 @@ func (app *Application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	// The route is protected by requireAuthentication, so there is always an authenticated user to record as the
+	// author of the snippet.
+	user := app.authenticatedUser(r)
+
+	// The snippet is saved with its tags, and as it was created as the first revision in its history.
+	id, err := app.snippets.Insert(form.Title, form.Content, form.Language, form.Visibility, form.expiresAt, user.ID,
+		form.BurnAfterReading, form.Tags)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -142,7 +318,412 @@ func (app *Application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created")
 
 	// Redirect the user to the relevant page for the snippet
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	// Read the snippet back to find its slug, which is in the URL of unlisted snippets.
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+}
+
+/*
+description: View every tag in use on an active snippet, with the number of snippets it is on
+route: /tags
+method: GET
+*/
+func (app *Application) tagList(w http.ResponseWriter, r *http.Request) {
+	tags, err := app.tags.GetAll()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tags = tags
+
+	app.render(w, http.StatusOK, "tags.go.html", data)
+}
+
+/*
+description: View the active snippets with a tag, one page at a time using the page query string parameter
+route: /tag/:name
+method: GET
+*/
+func (app *Application) tagView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	tag := strings.ToLower(params.ByName("name"))
+	if !validator.Matches(tag, validator.TagRX) {
+		app.notFound(w)
+		return
+	}
+
+	page, err := readInt(r, "page", 1, 1)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.GetByTag(tag, page, app.cfg.pageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.tags.Attach(snippets...)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tag = tag
+	data.Snippets = snippets
+	data.Metadata = metadata
+
+	app.render(w, http.StatusOK, "tag.go.html", data)
+}
+
+/*
+description: View the edit a snippet form, pre-filled with the current snippet
+route: /snippet/edit/:id
+method: GET
+*/
+func (app *Application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// Expires is left blank, which keeps the current expiry, unless the user chooses a new one.
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetCreateForm{
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Language:   snippet.Language,
+		Tags:       snippet.Tags,
+		Visibility: snippet.Visibility,
+	}
+
+	app.render(w, http.StatusOK, "edit.go.html", data)
+}
+
+/*
+description: Submit the edit a snippet form and redirect to the updated snippet at /snippet/view/:id
+route: /snippet/edit/:id
+method: POST
+*/
+func (app *Application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate(app.cfg.expiry, snippet)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "edit.go.html", data)
+		return
+	}
+
+	// A new revision is only recorded if the title or content changed.
+	err = app.snippets.Update(snippet.ID, form.Title, form.Content, form.Language, form.Visibility,
+		form.expiresAt, form.Tags, app.authenticatedUser(r).ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated")
+
+	// The visibility may have changed, which changes the snippet's URL.
+	snippet.Visibility = form.Visibility
+	http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+}
+
+/*
+description: Delete a snippet and redirect to the current user's snippets
+route: /snippet/delete/:id
+method: POST
+*/
+func (app *Application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully deleted")
+
+	http.Redirect(w, r, "/user/snippets", http.StatusSeeOther)
+}
+
+/*
+description: Extend the expiry of a snippet owned by the current user to a lifetime from now, and redirect to the
+snippet. The new expiry must be later than the current one.
+route: /snippet/extend/:id
+method: POST
+*/
+func (app *Application) snippetExtendPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetExtendForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var expires time.Time
+	d, never, err := app.cfg.expiry.Check(form.Expires)
+	switch {
+	case err != nil:
+		form.AddFieldsError("expires", err.Error())
+	case never:
+		expires = models.NeverExpires
+	default:
+		expires = time.Now().Add(d)
+	}
+	if form.Valid() {
+		form.CheckField(expires.After(snippet.Expires), "expires", "The new expiry must be later than the current one")
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "view.go.html", data)
+		return
+	}
+
+	err = app.snippets.Extend(snippet.ID, expires)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet expiry successfully extended")
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+}
+
+/*
+description: View the list of revisions of a snippet
+route: /snippet/view/:id/history
+method: GET
+*/
+func (app *Application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromParams(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.revisions.GetAll(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+
+	app.render(w, http.StatusOK, "history.go.html", data)
+}
+
+/*
+description: View the differences between two revisions of a snippet. The revisions are chosen with the from and
+to query string parameters, which default to the latest revision and the one before it, and the layout is chosen
+with the mode parameter, which is either unified (the default) or split.
+route: /snippet/view/:id/diff
+method: GET
+*/
+func (app *Application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromParams(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.revisions.GetAll(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if len(revisions) == 0 {
+		app.notFound(w)
+		return
+	}
+
+	// Revisions are returned newest first, so the default is to compare the first two.
+	to := revisions[0].Revision
+	from := max(to-1, 1)
+
+	query := r.URL.Query()
+	if v := query.Get("to"); v != "" {
+		to, err = strconv.Atoi(v)
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("from"); v != "" {
+		from, err = strconv.Atoi(v)
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	mode := query.Get("mode")
+	if mode != "split" {
+		mode = "unified"
+	}
+
+	// Look the two revisions up in the slice which has already been loaded, rather than querying for them again.
+	var fromRevision, toRevision *models.SnippetRevision
+	for _, rev := range revisions {
+		if rev.Revision == from {
+			fromRevision = rev
+		}
+		if rev.Revision == to {
+			toRevision = rev
+		}
+	}
+	if fromRevision == nil || toRevision == nil {
+		app.notFound(w)
+		return
+	}
+
+	lines := diff.Lines(fromRevision.Content, toRevision.Content)
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+	data.Diff = &DiffData{
+		From:    fromRevision,
+		To:      toRevision,
+		Mode:    mode,
+		Changed: diff.Changed(lines) || fromRevision.Title != toRevision.Title,
+		Lines:   lines,
+		Rows:    diff.SideBySide(lines),
+	}
+
+	app.render(w, http.StatusOK, "diff.go.html", data)
+}
+
+/*
+description: Restore an earlier revision of a snippet, saving it as a new revision, and redirect to the snippet
+route: /snippet/restore/:id
+method: POST
+*/
+func (app *Application) snippetRestorePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	number, err := strconv.Atoi(r.PostForm.Get("revision"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	revision, err := app.revisions.Get(snippet.ID, number)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// The restored content becomes a new revision, so the history is never rewritten.
+	err = app.snippets.UpdateContent(snippet.ID, revision.Title, revision.Content, app.authenticatedUser(r).ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Snippet restored to revision %d", number))
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.Ref(), http.StatusSeeOther)
+}
+
+/*
+description: View all active snippets created by the current user
+route: /user/snippets
+method: GET
+*/
+func (app *Application) userSnippets(w http.ResponseWriter, r *http.Request) {
+	page, err := readInt(r, "page", 1, 1)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.GetByUser(app.authenticatedUser(r).ID, page, app.cfg.pageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.tags.Attach(snippets...)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Metadata = metadata
+
+	app.render(w, http.StatusOK, "snippets.go.html", data)
 }
 
 /*
@@ -260,6 +841,12 @@ func (app *Application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 			data := app.newTemplateData(r)
 			data.Form = form
 			app.render(w, http.StatusUnprocessableEntity, "login.go.html", data)
+		} else if errors.Is(err, models.ErrInactiveUser) {
+			form.AddNonFieldError("This account has been deactivated")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusForbidden, "login.go.html", data)
 		} else {
 			app.serverError(w, err)
 		}
@@ -278,10 +865,109 @@ func (app *Application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	// Add the ID of the current user to the session, so that they are now logged in.
 	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
 
-	// Redirect the user to the create snippet page
+	// If the user was redirected to the login page by the requireAuthentication middleware, send them back to the
+	// page they originally requested. PopString() removes the value from the session so it is only used once.
+	path := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
+	if path != "" {
+		http.Redirect(w, r, path, http.StatusSeeOther)
+		return
+	}
+
+	// Otherwise redirect the user to the create snippet page
 	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
 }
 
+/*
+description: Display the current user's account page, with their API tokens and a form to create a new one
+route: /user/account
+method: GET
+*/
+func (app *Application) userAccount(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = tokenCreateForm{
+		Scope:   models.ScopeRead,
+		Expires: 90,
+	}
+
+	app.renderAccount(w, r, http.StatusOK, data)
+}
+
+/*
+description: Create a new API token and render the account page with the token, which is only ever shown this once
+route: /user/tokens
+method: POST
+*/
+func (app *Application) userTokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form tokenCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name",
+		"This field cannot be more than 100 characters long")
+	form.CheckField(validator.PermittedValue(form.Scope, models.ScopeRead, models.ScopeReadWrite), "scope",
+		"This field must be read or read-write")
+	form.CheckField(validator.PermittedInt(form.Expires, 0, 7, 30, 90, 365), "expires",
+		"This field must be equal to 0, 7, 30, 90, or 365")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.renderAccount(w, r, http.StatusUnprocessableEntity, data)
+		return
+	}
+
+	token, _, err := app.tokens.Insert(app.authenticatedUser(r).ID, form.Name, form.Scope, form.Expires)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Only a hash of the token is stored, so the plain-text token is rendered straight from this response rather
+	// than being kept anywhere for a redirect, and the response mustn't be cached.
+	data := app.newTemplateData(r)
+	data.Form = tokenCreateForm{
+		Scope:   models.ScopeRead,
+		Expires: 90,
+	}
+	data.NewToken = token
+	data.Flash = "API token created. Copy it now, it won't be shown again."
+
+	w.Header().Set("Cache-Control", "no-store")
+	app.renderAccount(w, r, http.StatusOK, data)
+}
+
+/*
+description: Revoke one of the current user's API tokens
+route: /user/tokens/revoke/:id
+method: POST
+*/
+func (app *Application) userTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "id")
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.tokens.Delete(id, app.authenticatedUser(r).ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "API token revoked")
+
+	http.Redirect(w, r, "/user/account", http.StatusSeeOther)
+}
+
 /*
 description: Logout the user
 route: /user/logout
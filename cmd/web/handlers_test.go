@@ -0,0 +1,491 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"github.com/rlr524/snippetboxv2/internal/models/mocks"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The fixture used by TestRoutes: Alice owns a public snippet tagged "go", and has a read-write API token. Bob is a
+// second user who owns nothing.
+const (
+	aliceEmail = "alice@example.com"
+	bobEmail   = "bob@example.com"
+)
+
+// routeFixture adds the TestRoutes fixture to the mocks and returns Alice's API token.
+func routeFixture(t *testing.T, store *mocks.Store) string {
+	t.Helper()
+
+	alice := addUser(t, store, "Alice", aliceEmail)
+	addUser(t, store, "Bob", bobEmail)
+
+	s := addSnippet(t, store, alice, "Haiku", models.VisibilityPublic, false)
+	err := store.Snippets.Update(s.ID, s.Title, s.Content, s.Language, s.Visibility, s.Expires, []string{"go"}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, _, err := store.Tokens.Insert(alice, "CLI", models.ScopeReadWrite, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// TestRoutes sends a request to every route registered by Routes, both anonymously and as a logged-in user, and
+// checks the status code and redirect of each response. Every case starts from a fresh copy of the fixture.
+func TestRoutes(t *testing.T) {
+	snippetForm := url.Values{
+		"title":      {"Autumn"},
+		"content":    {"Falling leaves"},
+		"expires":    {"1w"},
+		"visibility": {models.VisibilityPublic},
+		"tags":       {"poetry"},
+	}
+	snippetJSON := `{"title": "Autumn", "content": "Falling leaves", "expires": "1w", "visibility": "public"}`
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		// Only one of form and json is set for requests with a body. Forms are sent with the CSRF token.
+		form url.Values
+		json string
+		// user is the email of the user to log in as, or empty for an anonymous request.
+		user         string
+		wantStatus   int
+		wantLocation string
+		wantBody     string
+	}{
+		{name: "Static file", method: http.MethodGet, path: "/static/css/main.css", wantStatus: http.StatusOK},
+		{name: "Static directory", method: http.MethodGet, path: "/static/css/", wantStatus: http.StatusNotFound},
+		{name: "Home", method: http.MethodGet, path: "/", wantStatus: http.StatusOK, wantBody: "Haiku"},
+		{name: "Search", method: http.MethodGet, path: "/search?q=pond", wantStatus: http.StatusOK,
+			wantBody: "Haiku"},
+		{name: "Tags", method: http.MethodGet, path: "/tags", wantStatus: http.StatusOK, wantBody: "go"},
+		{name: "Tag", method: http.MethodGet, path: "/tag/go", wantStatus: http.StatusOK, wantBody: "Haiku"},
+		{name: "Invalid tag", method: http.MethodGet, path: "/tag/%20", wantStatus: http.StatusNotFound},
+		{name: "View", method: http.MethodGet, path: "/snippet/view/1", wantStatus: http.StatusOK,
+			wantBody: "An old silent pond..."},
+		{name: "View missing", method: http.MethodGet, path: "/snippet/view/99", wantStatus: http.StatusNotFound},
+		{name: "View invalid ID", method: http.MethodGet, path: "/snippet/view/-1",
+			wantStatus: http.StatusNotFound},
+		{name: "Confirm view", method: http.MethodPost, path: "/snippet/view/1", form: url.Values{},
+			wantStatus: http.StatusSeeOther, wantLocation: "/snippet/view/1"},
+		{name: "History", method: http.MethodGet, path: "/snippet/view/1/history", wantStatus: http.StatusOK},
+		{name: "Raw", method: http.MethodGet, path: "/snippet/raw/1", wantStatus: http.StatusOK,
+			wantBody: "An old silent pond..."},
+		{name: "Download", method: http.MethodGet, path: "/snippet/download/1", wantStatus: http.StatusOK,
+			wantBody: "An old silent pond..."},
+		{name: "Diff", method: http.MethodGet, path: "/snippet/view/1/diff", wantStatus: http.StatusOK},
+		{name: "Diff missing revision", method: http.MethodGet, path: "/snippet/view/1/diff?to=5",
+			wantStatus: http.StatusNotFound},
+		{name: "Signup", method: http.MethodGet, path: "/user/signup", wantStatus: http.StatusOK},
+		{name: "Signup post", method: http.MethodPost, path: "/user/signup",
+			form:       url.Values{"name": {"Carol"}, "email": {"carol@example.com"}, "password": {testPassword}},
+			wantStatus: http.StatusSeeOther, wantLocation: "/user/login"},
+		{name: "Signup duplicate email", method: http.MethodPost, path: "/user/signup",
+			form:       url.Values{"name": {"Alice"}, "email": {aliceEmail}, "password": {testPassword}},
+			wantStatus: http.StatusUnprocessableEntity, wantBody: "Email address is already in use"},
+		{name: "Login", method: http.MethodGet, path: "/user/login", wantStatus: http.StatusOK},
+		{name: "Login post", method: http.MethodPost, path: "/user/login",
+			form:       url.Values{"email": {aliceEmail}, "password": {testPassword}},
+			wantStatus: http.StatusSeeOther, wantLocation: "/snippet/create"},
+		{name: "Login wrong password", method: http.MethodPost, path: "/user/login",
+			form:       url.Values{"email": {aliceEmail}, "password": {"wrong password"}},
+			wantStatus: http.StatusUnprocessableEntity},
+		{name: "Post without CSRF token", method: http.MethodPost, path: "/user/login", json: `{}`,
+			wantStatus: http.StatusBadRequest},
+
+		{name: "Create anonymous", method: http.MethodGet, path: "/snippet/create",
+			wantStatus: http.StatusSeeOther, wantLocation: "/user/login"},
+		{name: "Create", method: http.MethodGet, path: "/snippet/create", user: aliceEmail,
+			wantStatus: http.StatusOK},
+		{name: "Create post anonymous", method: http.MethodPost, path: "/snippet/create", form: snippetForm,
+			wantStatus: http.StatusSeeOther, wantLocation: "/user/login"},
+		{name: "Create post", method: http.MethodPost, path: "/snippet/create", form: snippetForm,
+			user: aliceEmail, wantStatus: http.StatusSeeOther, wantLocation: "/snippet/view/2"},
+		{name: "Create post invalid", method: http.MethodPost, path: "/snippet/create",
+			form: url.Values{"title": {""}, "content": {""}, "expires": {"1w"}}, user: aliceEmail,
+			wantStatus: http.StatusUnprocessableEntity, wantBody: "This field cannot be blank"},
+		{name: "Edit anonymous", method: http.MethodGet, path: "/snippet/edit/1",
+			wantStatus: http.StatusSeeOther, wantLocation: "/user/login"},
+		{name: "Edit", method: http.MethodGet, path: "/snippet/edit/1", user: aliceEmail,
+			wantStatus: http.StatusOK},
+		{name: "Edit not owner", method: http.MethodGet, path: "/snippet/edit/1", user: bobEmail,
+			wantStatus: http.StatusForbidden},
+		{name: "Edit post", method: http.MethodPost, path: "/snippet/edit/1", form: snippetForm, user: aliceEmail,
+			wantStatus: http.StatusSeeOther, wantLocation: "/snippet/view/1"},
+		{name: "Edit post not owner", method: http.MethodPost, path: "/snippet/edit/1", form: snippetForm,
+			user: bobEmail, wantStatus: http.StatusForbidden},
+		{name: "Delete anonymous", method: http.MethodPost, path: "/snippet/delete/1", form: url.Values{},
+			wantStatus: http.StatusSeeOther, wantLocation: "/user/login"},
+		{name: "Delete", method: http.MethodPost, path: "/snippet/delete/1", form: url.Values{}, user: aliceEmail,
+			wantStatus: http.StatusSeeOther},
+		{name: "Delete not owner", method: http.MethodPost, path: "/snippet/delete/1", form: url.Values{},
+			user: bobEmail, wantStatus: http.StatusForbidden},
+		{name: "Extend", method: http.MethodPost, path: "/snippet/extend/1", form: url.Values{"expires": {"1y"}},
+			user: aliceEmail, wantStatus: http.StatusSeeOther, wantLocation: "/snippet/view/1"},
+		{name: "Extend shorter", method: http.MethodPost, path: "/snippet/extend/1",
+			form: url.Values{"expires": {"1h"}}, user: aliceEmail, wantStatus: http.StatusUnprocessableEntity},
+		{name: "Restore", method: http.MethodPost, path: "/snippet/restore/1", form: url.Values{"revision": {"1"}},
+			user: aliceEmail, wantStatus: http.StatusSeeOther, wantLocation: "/snippet/view/1"},
+		{name: "Restore missing revision", method: http.MethodPost, path: "/snippet/restore/1",
+			form: url.Values{"revision": {"9"}}, user: aliceEmail, wantStatus: http.StatusNotFound},
+		{name: "User snippets anonymous", method: http.MethodGet, path: "/user/snippets",
+			wantStatus: http.StatusSeeOther, wantLocation: "/user/login"},
+		{name: "User snippets", method: http.MethodGet, path: "/user/snippets", user: aliceEmail,
+			wantStatus: http.StatusOK, wantBody: "Haiku"},
+		{name: "Account", method: http.MethodGet, path: "/user/account", user: aliceEmail,
+			wantStatus: http.StatusOK, wantBody: "CLI"},
+		{name: "Create token", method: http.MethodPost, path: "/user/tokens",
+			form:       url.Values{"name": {"Laptop"}, "scope": {models.ScopeRead}, "expires": {"30"}},
+			user:       aliceEmail,
+			wantStatus: http.StatusOK, wantBody: "sbx_"},
+		{name: "Revoke token", method: http.MethodPost, path: "/user/tokens/revoke/1", form: url.Values{},
+			user: aliceEmail, wantStatus: http.StatusSeeOther, wantLocation: "/user/account"},
+		{name: "Revoke token not owner", method: http.MethodPost, path: "/user/tokens/revoke/1",
+			form: url.Values{}, user: bobEmail, wantStatus: http.StatusNotFound},
+		{name: "Logout", method: http.MethodPost, path: "/user/logout", form: url.Values{}, user: aliceEmail,
+			wantStatus: http.StatusSeeOther, wantLocation: "/"},
+
+		{name: "API list", method: http.MethodGet, path: "/api/v1/snippets", wantStatus: http.StatusOK,
+			wantBody: `"title": "Haiku"`},
+		{name: "API get", method: http.MethodGet, path: "/api/v1/snippets/1", wantStatus: http.StatusOK,
+			wantBody: `"tags": [`},
+		{name: "API get missing", method: http.MethodGet, path: "/api/v1/snippets/99",
+			wantStatus: http.StatusNotFound, wantBody: `"error"`},
+		{name: "API create anonymous", method: http.MethodPost, path: "/api/v1/snippets", json: snippetJSON,
+			wantStatus: http.StatusUnauthorized},
+		{name: "API create", method: http.MethodPost, path: "/api/v1/snippets", json: snippetJSON,
+			user: aliceEmail, wantStatus: http.StatusCreated, wantLocation: "/api/v1/snippets/2"},
+		{name: "API update", method: http.MethodPut, path: "/api/v1/snippets/1", json: snippetJSON,
+			user: aliceEmail, wantStatus: http.StatusOK, wantBody: `"title": "Autumn"`},
+		{name: "API update not owner", method: http.MethodPut, path: "/api/v1/snippets/1", json: snippetJSON,
+			user: bobEmail, wantStatus: http.StatusForbidden},
+		{name: "API delete", method: http.MethodDelete, path: "/api/v1/snippets/1", user: aliceEmail,
+			wantStatus: http.StatusNoContent},
+		{name: "API search", method: http.MethodGet, path: "/api/v1/search?q=pond", wantStatus: http.StatusOK,
+			wantBody: `"title": "Haiku"`},
+		{name: "API me anonymous", method: http.MethodGet, path: "/api/v1/me",
+			wantStatus: http.StatusUnauthorized},
+		{name: "API me", method: http.MethodGet, path: "/api/v1/me", user: aliceEmail, wantStatus: http.StatusOK,
+			wantBody: aliceEmail},
+		{name: "API not found", method: http.MethodGet, path: "/api/v1/nothing", wantStatus: http.StatusNotFound,
+			wantBody: `"error"`},
+		{name: "API method not allowed", method: http.MethodPatch, path: "/api/v1/snippets/1",
+			wantStatus: http.StatusMethodNotAllowed, wantBody: `"error"`},
+		{name: "Not found", method: http.MethodGet, path: "/nothing", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, store := newTestApplication(t)
+			routeFixture(t, store)
+			ts := newTestServer(t, app.Routes())
+
+			if tt.user != "" {
+				ts.login(t, tt.user, testPassword)
+			}
+
+			var rs testResponse
+			switch {
+			case tt.form != nil:
+				rs = ts.postForm(t, tt.path, tt.form)
+			case tt.json != "":
+				rs = ts.do(t, tt.method, tt.path, "application/json", strings.NewReader(tt.json), nil)
+			default:
+				rs = ts.do(t, tt.method, tt.path, "", nil, nil)
+			}
+
+			if rs.status != tt.wantStatus {
+				t.Errorf("got status %d; want %d", rs.status, tt.wantStatus)
+			}
+			if tt.wantLocation != "" && rs.header.Get("Location") != tt.wantLocation {
+				t.Errorf("got Location %q; want %q", rs.header.Get("Location"), tt.wantLocation)
+			}
+			if !strings.Contains(rs.body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+// TestCSRFSession checks that the CSRF token is only put in the session when a page with a form is rendered, so that
+// anonymous visitors to other pages don't each create a session.
+func TestCSRFSession(t *testing.T) {
+	app, store := newTestApplication(t)
+	alice := addUser(t, store, "Alice", aliceEmail)
+	s := addSnippet(t, store, alice, "Haiku", models.VisibilityPublic, false)
+
+	ts := newTestServer(t, app.Routes())
+
+	for _, path := range []string{"/", "/snippet/view/" + s.Ref(), "/snippet/raw/" + s.Ref()} {
+		rs := ts.get(t, path)
+		if rs.status != http.StatusOK {
+			t.Fatalf("%s: got status %d; want %d", path, rs.status, http.StatusOK)
+		}
+		if cookie := rs.header.Get("Set-Cookie"); cookie != "" {
+			t.Errorf("%s: got a session cookie %q; want none", path, cookie)
+		}
+	}
+
+	// A form can't be posted before one has been shown, as the session has no token to compare with.
+	rs := ts.do(t, http.MethodPost, "/user/login", "application/x-www-form-urlencoded",
+		strings.NewReader(url.Values{csrfTokenField: {""}}.Encode()), nil)
+	if rs.status != http.StatusBadRequest {
+		t.Errorf("posting without a token in the session: got status %d; want %d", rs.status,
+			http.StatusBadRequest)
+	}
+
+	rs = ts.get(t, "/user/login")
+	if rs.header.Get("Set-Cookie") == "" {
+		t.Error("/user/login: got no session cookie; want one holding the CSRF token")
+	}
+}
+
+// TestSnippetVisibility checks that unlisted and private snippets are hidden from everyone who shouldn't see them,
+// and that they are reported as missing rather than forbidden.
+func TestSnippetVisibility(t *testing.T) {
+	app, store := newTestApplication(t)
+	alice := addUser(t, store, "Alice", aliceEmail)
+	addUser(t, store, "Bob", bobEmail)
+
+	unlisted := addSnippet(t, store, alice, "Unlisted", models.VisibilityUnlisted, false)
+	private := addSnippet(t, store, alice, "Private", models.VisibilityPrivate, false)
+	expired := addSnippet(t, store, alice, "Expired", models.VisibilityPublic, false)
+	store.Snippets.Expire(expired.ID)
+
+	tests := []struct {
+		name       string
+		user       string
+		path       string
+		wantStatus int
+	}{
+		{"Unlisted by slug", "", "/snippet/view/" + unlisted.Slug, http.StatusOK},
+		{"Unlisted by ID", "", "/snippet/view/" + strconv.Itoa(unlisted.ID), http.StatusNotFound},
+		{"Unlisted raw by slug", "", "/snippet/raw/" + unlisted.Slug, http.StatusOK},
+		{"Private anonymous", "", "/snippet/view/" + strconv.Itoa(private.ID), http.StatusNotFound},
+		{"Private other user", bobEmail, "/snippet/view/" + strconv.Itoa(private.ID), http.StatusNotFound},
+		{"Private author", aliceEmail, "/snippet/view/" + strconv.Itoa(private.ID), http.StatusOK},
+		{"Private API", bobEmail, "/api/v1/snippets/" + strconv.Itoa(private.ID), http.StatusNotFound},
+		{"Expired", aliceEmail, "/snippet/view/" + strconv.Itoa(expired.ID), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.Routes())
+			if tt.user != "" {
+				ts.login(t, tt.user, testPassword)
+			}
+
+			rs := ts.get(t, tt.path)
+			if rs.status != tt.wantStatus {
+				t.Errorf("got status %d; want %d", rs.status, tt.wantStatus)
+			}
+		})
+	}
+
+	// None of them are listed anywhere, even for their author.
+	ts := newTestServer(t, app.Routes())
+	ts.login(t, aliceEmail, testPassword)
+	for _, path := range []string{"/", "/search?q=pond", "/api/v1/snippets"} {
+		rs := ts.get(t, path)
+		for _, title := range []string{"Unlisted", "Private", "Expired"} {
+			if strings.Contains(rs.body, title) {
+				t.Errorf("%s lists the %s snippet", path, strings.ToLower(title))
+			}
+		}
+	}
+}
+
+// TestBurnAfterReading checks that a burn after reading snippet is only shown once, and only after the reader
+// confirms with a POST.
+func TestBurnAfterReading(t *testing.T) {
+	app, store := newTestApplication(t)
+	alice := addUser(t, store, "Alice", aliceEmail)
+	s := addSnippet(t, store, alice, "Secret", models.VisibilityUnlisted, true)
+
+	ts := newTestServer(t, app.Routes())
+	path := "/snippet/view/" + s.Slug
+
+	// Viewing the snippet only shows the confirmation page, so link previews don't burn it.
+	rs := ts.get(t, path)
+	if rs.status != http.StatusOK || strings.Contains(rs.body, "An old silent pond...") {
+		t.Fatalf("GET: got status %d, want the confirmation page without the content", rs.status)
+	}
+	if rs.header.Get("Cache-Control") != "no-store" {
+		t.Errorf("GET: got Cache-Control %q; want no-store", rs.header.Get("Cache-Control"))
+	}
+
+	// The raw view isn't available, as it would skip the confirmation.
+	if rs := ts.get(t, "/snippet/raw/"+s.Slug); rs.status != http.StatusNotFound {
+		t.Errorf("raw: got status %d; want %d", rs.status, http.StatusNotFound)
+	}
+
+	rs = ts.postForm(t, path, url.Values{})
+	if rs.status != http.StatusOK || !strings.Contains(rs.body, "An old silent pond...") {
+		t.Fatalf("first POST: got status %d, want the content", rs.status)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if method == http.MethodGet {
+			rs = ts.get(t, path)
+		} else {
+			rs = ts.postForm(t, path, url.Values{})
+		}
+		if rs.status != http.StatusGone || strings.Contains(rs.body, "An old silent pond...") {
+			t.Errorf("%s after reading: got status %d; want %d without the content", method, rs.status,
+				http.StatusGone)
+		}
+	}
+
+	// The API never burns the snippet on a GET, which may be retried or prefetched, but only on a POST to read it.
+	s = addSnippet(t, store, alice, "API secret", models.VisibilityUnlisted, true)
+	apiPath := "/api/v1/snippets/" + s.Slug
+	for i := range 2 {
+		rs := ts.get(t, apiPath)
+		if rs.status != http.StatusConflict || strings.Contains(rs.body, "An old silent pond...") {
+			t.Errorf("API GET %d: got status %d; want %d without the content", i+1, rs.status,
+				http.StatusConflict)
+		}
+	}
+
+	rs = ts.do(t, http.MethodPost, apiPath+"/read", "", nil, nil)
+	if rs.status != http.StatusOK || !strings.Contains(rs.body, "An old silent pond...") {
+		t.Fatalf("API read: got status %d; want %d and the content", rs.status, http.StatusOK)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		path := apiPath
+		if method == http.MethodPost {
+			path += "/read"
+		}
+		if rs := ts.do(t, method, path, "", nil, nil); rs.status != http.StatusGone {
+			t.Errorf("API %s after reading: got status %d; want %d", method, rs.status, http.StatusGone)
+		}
+	}
+}
+
+// TestAPITokens checks that API requests can be authenticated with a bearer token instead of the session, and
+// that read-only tokens can't change data.
+func TestAPITokens(t *testing.T) {
+	app, store := newTestApplication(t)
+	alice := addUser(t, store, "Alice", aliceEmail)
+	addSnippet(t, store, alice, "Haiku", models.VisibilityPublic, false)
+
+	readWrite, _, err := store.Tokens.Insert(alice, "CI", models.ScopeReadWrite, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readOnly, _, err := store.Tokens.Insert(alice, "Dashboard", models.ScopeRead, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.Routes())
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		wantStatus    int
+	}{
+		{"Read with read-only token", http.MethodGet, "/api/v1/me", "Bearer " + readOnly, http.StatusOK},
+		{"Write with read-only token", http.MethodDelete, "/api/v1/snippets/1", "Bearer " + readOnly,
+			http.StatusForbidden},
+		{"Unknown token", http.MethodGet, "/api/v1/me", "Bearer sbx_unknown", http.StatusUnauthorized},
+		{"Malformed header", http.MethodGet, "/api/v1/me", "Basic " + readWrite, http.StatusUnauthorized},
+		{"Write with read-write token", http.MethodDelete, "/api/v1/snippets/1", "Bearer " + readWrite,
+			http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := ts.do(t, tt.method, tt.path, "", nil, http.Header{"Authorization": {tt.authorization}})
+			if rs.status != tt.wantStatus {
+				t.Errorf("got status %d; want %d", rs.status, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rs.header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("got WWW-Authenticate %q; want Bearer", rs.header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	rs := ts.do(t, http.MethodGet, "/api/v1/me", "", nil, http.Header{"Authorization": {"Bearer " + readWrite}})
+
+	var body struct {
+		User struct {
+			ID int `json:"id"`
+		} `json:"user"`
+	}
+	err = json.Unmarshal([]byte(rs.body), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.User.ID != alice {
+		t.Errorf("got user %d; want %d", body.User.ID, alice)
+	}
+}
+
+// TestCreateAPIToken checks that a new API token is shown in the response to the form which creates it, and isn't
+// shown again when the account page is next loaded.
+func TestCreateAPIToken(t *testing.T) {
+	app, store := newTestApplication(t)
+	addUser(t, store, "Alice", aliceEmail)
+
+	ts := newTestServer(t, app.Routes())
+	ts.login(t, aliceEmail, testPassword)
+
+	rs := ts.postForm(t, "/user/tokens", url.Values{"name": {"Laptop"}, "scope": {models.ScopeRead},
+		"expires": {"30"}})
+	if rs.status != http.StatusOK {
+		t.Fatalf("got status %d; want %d", rs.status, http.StatusOK)
+	}
+	if !strings.Contains(rs.body, "sbx_mock1") {
+		t.Errorf("got body %q; want it to contain the new token", rs.body)
+	}
+	if rs.header.Get("Cache-Control") != "no-store" {
+		t.Errorf("got Cache-Control %q; want no-store", rs.header.Get("Cache-Control"))
+	}
+
+	rs = ts.get(t, "/user/account")
+	if !strings.Contains(rs.body, "Laptop") || strings.Contains(rs.body, "sbx_mock1") {
+		t.Errorf("got body %q; want the token listed by name only", rs.body)
+	}
+}
+
+// TestDeactivatedUser checks that a logged-in user who is deactivated is treated as anonymous on their next request.
+func TestDeactivatedUser(t *testing.T) {
+	app, store := newTestApplication(t)
+	alice := addUser(t, store, "Alice", aliceEmail)
+
+	ts := newTestServer(t, app.Routes())
+	ts.login(t, aliceEmail, testPassword)
+
+	if rs := ts.get(t, "/user/account"); rs.status != http.StatusOK {
+		t.Fatalf("got status %d; want %d", rs.status, http.StatusOK)
+	}
+
+	store.Users.Deactivate(alice)
+
+	rs := ts.get(t, "/user/account")
+	if rs.status != http.StatusSeeOther || rs.header.Get("Location") != "/user/login" {
+		t.Errorf("got status %d redirecting to %q; want %d redirecting to /user/login", rs.status,
+			rs.header.Get("Location"), http.StatusSeeOther)
+	}
+
+	// Logging in again is refused with a message saying why.
+	rs = ts.postForm(t, "/user/login", url.Values{"email": {aliceEmail}, "password": {testPassword}})
+	if rs.status != http.StatusForbidden || !strings.Contains(rs.body, "This account has been deactivated") {
+		t.Errorf("logging in as a deactivated user: got status %d; want %d and a message", rs.status,
+			http.StatusForbidden)
+	}
+}
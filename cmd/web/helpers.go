@@ -2,11 +2,18 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rlr524/snippetboxv2/internal/highlight"
+	"github.com/rlr524/snippetboxv2/internal/models"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -41,15 +48,18 @@ func (app *Application) decodePostForm(r *http.Request, dst any) error {
 // generic 500 Internal Server Error response to the user. The debug.Stack() function gets a stack
 // trace from the current goroutine and appends it to the log message.
 func (app *Application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	e := app.errorLog.Output(2, trace)
-	if e != nil {
-		return
-	}
+	app.logError(err)
 
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
+// The logError helper writes an error message and stack trace to the errorLog. The call depth of 3 reports the
+// file and line of the handler which called serverError (or apiServerError), rather than of the helpers themselves.
+func (app *Application) logError(err error) {
+	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	_ = app.errorLog.Output(3, trace)
+}
+
 // The clientError helper sends a specific code and corresponding description to the user, such as 400
 // "Bad Request" responses when there is a problem with a user request. The http.StatusText() function
 // generates a human-friendly text representation of a given HTTP status code.
@@ -100,12 +110,208 @@ func (app *Application) newTemplateData(r *http.Request) *TemplateData {
 		CurrentYear: time.Now().Year(),
 		// Add the flash toast message to the template data, if one exists.
 		Flash: app.sessionManager.PopString(r.Context(), "flash"),
-		// Add the authentication status to the template data.
+		// Add the authentication status and the current user to the template data.
 		IsAuthenticated: app.isAuthenticated(r),
+		User:            app.authenticatedUser(r),
+		// Give the templates a way to get the CSRF token, for the hidden field in every form.
+		csrfToken: func() (string, error) {
+			return app.csrfToken(r)
+		},
+		// Add the current URL, so that links such as pagination can keep the existing query string.
+		CurrentURL: r.URL,
+		// Add the expiry choices allowed by the policy, for the create, edit and extend snippet forms.
+		ExpiryPresets: app.cfg.expiry.Presets(),
 	}
 }
 
-// The isAuthenticated helper returns true id the current request is from an authenticated user, otherwise false.
+// The isAuthenticated helper returns true if the current request is from an authenticated user, otherwise false.
+// The value is set in the request context by the authenticate middleware.
 func (app *Application) isAuthenticated(r *http.Request) bool {
-	return app.sessionManager.Exists(r.Context(), "authenticatedUserID")
+	isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
+	if !ok {
+		return false
+	}
+	return isAuthenticated
+}
+
+// The authenticatedUser helper returns the user for the current request, or nil if the request is from an
+// anonymous user.
+func (app *Application) authenticatedUser(r *http.Request) *models.User {
+	user, ok := r.Context().Value(authenticatedUserContextKey).(*models.User)
+	if !ok {
+		return nil
+	}
+	return user
+}
+
+// The intParam helper returns the value of the named httprouter parameter as an int.
+func intParam(r *http.Request, name string) (int, error) {
+	// When httprouter is parsing a request, the values of any named parameters will be stored in the request context.
+	params := httprouter.ParamsFromContext(r.Context())
+	return strconv.Atoi(params.ByName(name))
+}
+
+// The lookupSnippet helper returns the live snippet referred to by ref, if the current user is allowed to see it. A
+// numeric ref is an ID, which reaches public snippets and the user's own private snippets; anything else is a slug,
+// which only reaches unlisted snippets. Snippets the user isn't allowed to see are reported as models.ErrNoRecord,
+// so that a 404 rather than a 403 is sent and their existence isn't revealed.
+func (app *Application) lookupSnippet(r *http.Request, ref string) (*models.Snippet, error) {
+	var userID int
+	if user := app.authenticatedUser(r); user != nil {
+		userID = user.ID
+	}
+
+	id, err := strconv.Atoi(ref)
+	if err == nil {
+		if id < 1 {
+			return nil, models.ErrNoRecord
+		}
+
+		snippet, err := app.snippets.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if !snippet.VisibleTo(userID) {
+			return nil, models.ErrNoRecord
+		}
+		return snippet, nil
+	}
+
+	snippet, err := app.snippets.GetBySlug(ref)
+	if err != nil {
+		return nil, err
+	}
+	if snippet.Visibility != models.VisibilityUnlisted {
+		return nil, models.ErrNoRecord
+	}
+	return snippet, nil
+}
+
+// The loadSnippet helper loads the live snippet named by the :id route parameter, which is either its ID or its slug,
+// along with its tags. If the snippet doesn't exist or the current user isn't allowed to see it a 404 is sent, ok is
+// false, and the caller should return without writing anything else.
+func (app *Application) loadSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	snippet, err := app.lookupSnippet(r, params.ByName("id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	err = app.tags.Attach(snippet)
+	if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// The snippetFromParams helper is like loadSnippet, but also sends a 404 for burn after reading snippets. Their
+// content must only ever be shown by snippetViewPost, which marks them as read, so every other page which shows a
+// snippet's content uses this helper.
+func (app *Application) snippetFromParams(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.loadSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if snippet.BurnAfterReading {
+		app.notFound(w)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// The ownedSnippet helper loads the snippet named by the :id route parameter and checks that it belongs to the
+// authenticated user. If it doesn't exist a 404 is sent, and if it belongs to somebody else a 403 is sent; in
+// both cases ok is false and the caller should return without writing anything else. Burn after reading snippets are
+// included, as their author already knows what is in them.
+func (app *Application) ownedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.loadSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	user := app.authenticatedUser(r)
+	if user == nil || snippet.CreatedBy.ID != user.ID {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// The renderAccount helper adds the current user's API tokens to the template data and renders the account page.
+func (app *Application) renderAccount(w http.ResponseWriter, r *http.Request, status int, data *TemplateData) {
+	tokens, err := app.tokens.GetAllForUser(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data.Tokens = tokens
+
+	app.render(w, status, "account.go.html", data)
+}
+
+// The readInt helper returns the value of the named query string parameter as an int, or def if it is missing. An
+// error is returned if the parameter is present but isn't an integer of at least min.
+func readInt(r *http.Request, name string, def, min int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil || i < min {
+		return 0, fmt.Errorf("the %s parameter must be an integer of at least %d", name, min)
+	}
+	return i, nil
+}
+
+// The serveSnippetContent helper writes the content of a snippet as UTF-8 plain text. http.ServeContent() handles
+// Range requests and conditional requests, using the ETag which is set here from a hash of the content. The
+// X-Content-Type-Options: nosniff header set by secureHeaders stops browsers treating the text as HTML.
+func (app *Application) serveSnippetContent(w http.ResponseWriter, r *http.Request, snippet *models.Snippet) {
+	hash := sha256.Sum256([]byte(snippet.Content))
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(hash[:16])+`"`)
+	// Snippets can be edited, so caches must revalidate with the ETag before reusing a stored copy.
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// The snippets table has no modification time, so a zero time.Time is passed and only the ETag is used.
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(snippet.Content))
+}
+
+// The snippetFilename helper returns a file name for a snippet made from its title and the extension for its
+// language, such as "hello-world.go". Only ASCII letters and digits are kept from the title.
+func snippetFilename(snippet *models.Snippet) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(snippet.Title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+		if b.Len() >= 50 {
+			break
+		}
+	}
+
+	name := strings.Trim(b.String(), "-")
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", snippet.ID)
+	}
+	return name + highlight.Extension(snippet.Language)
 }
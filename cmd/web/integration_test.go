@@ -0,0 +1,168 @@
+package main
+
+import (
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-playground/form/v4"
+	"github.com/rlr524/snippetboxv2/internal/expiry"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"github.com/rlr524/snippetboxv2/internal/models/dbtest"
+	"github.com/rlr524/snippetboxv2/internal/seed"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newIntegrationApplication returns an Application backed by db, with the sessions stored in the database as they
+// are by main, so that a test can drive the whole application against a real database. The logs are discarded.
+func newIntegrationApplication(t *testing.T, db *models.DB) *Application {
+	t.Helper()
+
+	templateCache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cleanup goroutine is left off, as it would outlive the database.
+	sessionManager := scs.New()
+	sessionManager.Store = newSessionStore(db, 0)
+	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Cookie.Secure = true
+
+	return &Application{
+		errorLog:       log.New(io.Discard, "", 0),
+		infoLog:        log.New(io.Discard, "", 0),
+		cfg:            Config{pageSize: 10, expiry: expiry.DefaultPolicy},
+		snippets:       &models.SnippetModel{DB: db, FullText: db.Dialect == models.MySQL},
+		users:          &models.UserModel{DB: db},
+		revisions:      &models.SnippetRevisionModel{DB: db},
+		tokens:         &models.TokenModel{DB: db},
+		tags:           &models.TagModel{DB: db},
+		sessions:       &models.SessionModel{DB: db},
+		templateCache:  templateCache,
+		formDecoder:    form.NewDecoder(),
+		sessionManager: sessionManager,
+	}
+}
+
+func TestEndToEnd(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		ts := newTestServer(t, newIntegrationApplication(t, db).Routes())
+
+		rs := ts.get(t, "/")
+		if rs.status != http.StatusOK || !strings.Contains(rs.body, "There's nothing to see here yet") {
+			t.Fatalf("home page of an empty database: got status %d", rs.status)
+		}
+
+		signup := url.Values{"name": {"Alice"}, "email": {aliceEmail}, "password": {testPassword}}
+		rs = ts.postForm(t, "/user/signup", signup)
+		if rs.status != http.StatusSeeOther || rs.header.Get("Location") != "/user/login" {
+			t.Fatalf("signing up: got status %d redirecting to %q", rs.status, rs.header.Get("Location"))
+		}
+
+		rs = ts.postForm(t, "/user/signup", signup)
+		if rs.status != http.StatusUnprocessableEntity || !strings.Contains(rs.body, "Email address is already in use") {
+			t.Errorf("signing up twice: got status %d; want %d and a duplicate email error", rs.status,
+				http.StatusUnprocessableEntity)
+		}
+
+		rs = ts.postForm(t, "/user/login", url.Values{"email": {aliceEmail}, "password": {"wrong password"}})
+		if rs.status != http.StatusUnprocessableEntity {
+			t.Errorf("logging in with the wrong password: got status %d; want %d", rs.status,
+				http.StatusUnprocessableEntity)
+		}
+
+		ts.login(t, aliceEmail, testPassword)
+
+		// The session is kept in the database rather than in memory.
+		var sessions int
+		err := db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&sessions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sessions == 0 {
+			t.Error("got no sessions stored in the database")
+		}
+
+		paths := map[string]string{}
+		for _, visibility := range []string{models.VisibilityPublic, models.VisibilityPrivate} {
+			rs = ts.postForm(t, "/snippet/create", url.Values{
+				"title":      {"An old silent pond (" + visibility + ")"},
+				"content":    {"An old silent pond..."},
+				"expires":    {"1w"},
+				"visibility": {visibility},
+				"tags":       {"haiku"},
+			})
+			if rs.status != http.StatusSeeOther || !strings.HasPrefix(rs.header.Get("Location"), "/snippet/view/") {
+				t.Fatalf("creating a %s snippet: got status %d redirecting to %q", visibility, rs.status,
+					rs.header.Get("Location"))
+			}
+			paths[visibility] = rs.header.Get("Location")
+		}
+
+		for visibility, path := range paths {
+			rs = ts.get(t, path)
+			if rs.status != http.StatusOK || !strings.Contains(rs.body, "An old silent pond ("+visibility+")") {
+				t.Errorf("viewing the %s snippet: got status %d; want %d and its title", visibility, rs.status,
+					http.StatusOK)
+			}
+		}
+
+		rs = ts.get(t, "/")
+		if !strings.Contains(rs.body, "An old silent pond (public)") ||
+			strings.Contains(rs.body, "An old silent pond (private)") {
+			t.Error("home page: want the public snippet listed and the private one not")
+		}
+
+		rs = ts.postForm(t, "/user/logout", nil)
+		if rs.status != http.StatusSeeOther {
+			t.Fatalf("logging out: got status %d; want %d", rs.status, http.StatusSeeOther)
+		}
+
+		if rs = ts.get(t, paths[models.VisibilityPublic]); rs.status != http.StatusOK {
+			t.Errorf("viewing the public snippet logged out: got status %d; want %d", rs.status, http.StatusOK)
+		}
+		if rs = ts.get(t, paths[models.VisibilityPrivate]); rs.status != http.StatusNotFound {
+			t.Errorf("viewing the private snippet logged out: got status %d; want %d", rs.status,
+				http.StatusNotFound)
+		}
+	})
+}
+
+func TestSeededDatabase(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		app := newIntegrationApplication(t, db)
+		loaded, err := seed.Load(seed.Repositories{Users: app.users, Snippets: app.snippets}, seed.Demo(12))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ts := newTestServer(t, app.Routes())
+
+		rs := ts.get(t, "/")
+		if rs.status != http.StatusOK || strings.Contains(rs.body, "There's nothing to see here yet") {
+			t.Errorf("home page of a seeded database: got status %d; want %d and some snippets", rs.status,
+				http.StatusOK)
+		}
+
+		// The demo users can log in and see their own snippets, whatever their visibility.
+		ts.login(t, "alice@example.com", seed.DemoPassword)
+		rs = ts.get(t, "/user/snippets")
+		if rs.status != http.StatusOK || !strings.Contains(rs.body, "Shopping list") {
+			t.Errorf("own snippets of a demo user: got status %d; want %d and the private shopping list",
+				rs.status, http.StatusOK)
+		}
+
+		if rs = ts.get(t, "/tag/go"); rs.status != http.StatusOK || !strings.Contains(rs.body, "Reverse a string") {
+			t.Errorf("snippets tagged go: got status %d; want %d and the seeded Go snippet", rs.status,
+				http.StatusOK)
+		}
+
+		if len(loaded.Snippets) != 12 {
+			t.Errorf("got %d snippets loaded; want 12", len(loaded.Snippets))
+		}
+	})
+}
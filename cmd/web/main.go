@@ -1,20 +1,28 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
-	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/postgresstore"
+	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/joho/godotenv"
+	"github.com/rlr524/snippetboxv2/internal/expiry"
 	"github.com/rlr524/snippetboxv2/internal/models"
+	"html/template"
+	"io/fs"
 	"log"
+	_ "modernc.org/sqlite"
 	"net/http"
 	"os"
-	"text/template"
+	"sync"
 	"time"
 )
 
@@ -22,25 +30,44 @@ type Config struct {
 	addr string
 	//staticDir string
 	env string
-	dsn string
+	// dbDriver selects the database, and so the SQL dialect of the models and the session store.
+	dbDriver string
+	dsn      string
+	pageSize int
+	// expiry is the range of lifetimes snippets are allowed to have.
+	expiry expiry.Policy
+	reaper reaperConfig
+	// purgeExpired runs the reaper once and exits, instead of starting the server.
+	purgeExpired bool
+	// migrate is a -migrate command (up, down or status) to run instead of starting the server.
+	migrate string
+	// shutdownTimeout is how long in-flight requests and background work are given to finish on shutdown.
+	shutdownTimeout time.Duration
 }
 
 type Application struct {
 	errorLog       *log.Logger
 	infoLog        *log.Logger
 	cfg            Config
-	snippets       *models.SnippetModel
-	users          *models.UserModel
+	snippets       models.SnippetRepository
+	users          models.UserRepository
+	revisions      models.RevisionRepository
+	tokens         models.TokenRepository
+	tags           models.TagRepository
+	sessions       models.SessionRepository
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	// wg tracks the goroutines started with background, so that shutdown can wait for them.
+	wg sync.WaitGroup
 }
 
 func main() {
 	var cfg Config
 
+	// The .env file is optional, as it only holds the database password.
 	err := godotenv.Load()
-	if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		log.Fatal("Error loading .env file")
 	}
 
@@ -48,25 +75,85 @@ func main() {
 	flag.StringVar(&cfg.addr, "addr", ":4000", "HTTP network address")
 	//flag.StringVar(&cfg.staticDir, "static-dir", "./ui/static/", "Path to static assets")
 	flag.StringVar(&cfg.env, "env", "production", "Environment (development|staging|production)")
-	flag.StringVar(&cfg.dsn, "dsn", fmt.Sprintf("web:%s@tcp(lancer:3306)/snippetbox?parseTime=true", dbPass),
-		"MySQL data source")
+	flag.StringVar(&cfg.dbDriver, "db-driver", string(models.MySQL), "Database driver (mysql|postgres|sqlite)")
+	flag.StringVar(&cfg.dsn, "dsn", "", "Data source name (default depends on -db-driver)")
+	flag.IntVar(&cfg.pageSize, "page-size", 10, fmt.Sprintf("Number of snippets per page (maximum %d)",
+		models.MaxPageSize))
+	cfg.expiry = expiry.DefaultPolicy
+	flag.Func("min-expiry", "Shortest lifetime a snippet can have, such as 10m or 1h (default 1m)",
+		lifetimeFlag(&cfg.expiry.Min))
+	flag.Func("max-expiry", "Longest lifetime a snippet can have, such as 30d or 1y (default no maximum)",
+		lifetimeFlag(&cfg.expiry.Max))
+	flag.BoolVar(&cfg.expiry.AllowNever, "allow-never", cfg.expiry.AllowNever, "Allow snippets which never expire")
+	flag.DurationVar(&cfg.reaper.interval, "reap-interval", 10*time.Minute,
+		"Time between purges of expired snippets and sessions (0 to disable)")
+	flag.IntVar(&cfg.reaper.batchSize, "reap-batch", 500, "Number of expired snippets purged per transaction")
+	flag.BoolVar(&cfg.reaper.archive, "archive-expired", false,
+		"Move expired snippets to the snippets_archive table instead of deleting them")
+	flag.BoolVar(&cfg.purgeExpired, "purge-expired", false,
+		"Purge expired snippets and sessions once and exit, for running from cron")
+	flag.StringVar(&cfg.migrate, "migrate", "", "Run schema migrations (up|down|status) and exit")
+	flag.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 20*time.Second,
+		"Time allowed for in-flight requests and background work to finish on shutdown")
 	flag.Parse()
 
+	if !models.Dialect(cfg.dbDriver).Supported() {
+		log.Fatalf("Unsupported database driver %q", cfg.dbDriver)
+	}
+	if cfg.dsn == "" {
+		cfg.dsn = models.Dialect(cfg.dbDriver).DefaultDSN(dbPass)
+	}
+
+	switch cfg.migrate {
+	case "", "up", "down", "status":
+	default:
+		log.Fatalf("Unknown -migrate command %q", cfg.migrate)
+	}
+
+	cfg.reaper.batchSize = max(cfg.reaper.batchSize, 1)
+
+	cfg.pageSize = models.ClampPageSize(cfg.pageSize)
+
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Llongfile)
 
-	db, err := openDB(cfg.dsn)
+	db, err := models.Open(models.Dialect(cfg.dbDriver), cfg.dsn)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 
-	defer func(db *sql.DB) {
+	defer func(db *models.DB) {
 		err := db.Close()
 		if err != nil {
 			errorLog.Print(err)
 		}
 	}(db)
 
+	// In -migrate mode, run the migrations and exit without starting the server.
+	if cfg.migrate != "" {
+		err = migrate(db, cfg.migrate, infoLog)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		return
+	}
+
+	// A new SQLite database file is migrated straight away, so development needs no database server. MySQL and
+	// Postgres databases are migrated with -migrate up, as the application's user shouldn't be able to change the
+	// schema.
+	if db.Dialect == models.SQLite {
+		_, err = db.MigrateUp()
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+
+	// Refuse to start against a schema the models weren't written for.
+	err = db.CheckSchema()
+	if err != nil {
+		errorLog.Fatalf("%v; run with -migrate status to see which migrations have been applied", err)
+	}
+
 	// Init a new template cache
 	templateCache, err := newTemplateCache()
 	if err != nil {
@@ -76,23 +163,51 @@ func main() {
 	// Initialize a new decoder instance
 	formDecoder := form.NewDecoder()
 
-	// Initialize a new session manager and configure it to use the MySQL database
-	// as the session store and set a lifetime of 12 hours.
+	// Initialize a new session manager and configure it to use the database as the session store and set a
+	// lifetime of 12 hours.
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
+	// The reaper deletes expired sessions along with expired snippets, so the store's own cleanup goroutine is only
+	// used when the reaper is disabled.
+	cleanupInterval := 5 * time.Minute
+	if cfg.reaper.interval > 0 || cfg.purgeExpired {
+		cleanupInterval = 0
+	}
+	sessionManager.Store = newSessionStore(db, cleanupInterval)
 	sessionManager.Lifetime = 12 * time.Hour
 	sessionManager.Cookie.Secure = true
+	// Only send the session cookie on same-site requests and top-level navigations, as a second line of defence
+	// against CSRF alongside the token checked by the verifyCSRF middleware.
+	sessionManager.Cookie.SameSite = http.SameSiteLaxMode
+	sessionManager.Cookie.HttpOnly = true
 
 	app := &Application{
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		snippets:       &models.SnippetModel{DB: db},
+		errorLog: errorLog,
+		infoLog:  infoLog,
+		cfg:      cfg,
+		// Only MySQL has a FULLTEXT index to search with.
+		snippets:       &models.SnippetModel{DB: db, FullText: db.Dialect == models.MySQL},
 		users:          &models.UserModel{DB: db},
+		revisions:      &models.SnippetRevisionModel{DB: db},
+		tokens:         &models.TokenModel{DB: db},
+		tags:           &models.TagModel{DB: db},
+		sessions:       &models.SessionModel{DB: db},
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
 	}
 
+	// In -purge-expired mode, purge once and exit without starting the server.
+	if cfg.purgeExpired {
+		start := time.Now()
+		snippets, sessions, err := app.purgeExpired(context.Background())
+		if err != nil {
+			// Exit with a non-zero status so that cron reports the failure.
+			errorLog.Fatal(err)
+		}
+		app.logPurge(snippets, sessions, time.Since(start))
+		return
+	}
+
 	// Config struct to hold the non-default TLS settings
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
@@ -116,20 +231,36 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
-	infoLog.Printf("Starting server on %s", cfg.addr)
-	e := srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(e)
+	// Serve until the process is told to stop. A graceful shutdown returns nil, so the deferred db.Close() runs.
+	err = app.serve(srv)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
 }
 
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		return nil, err
+// newSessionStore returns a session store which keeps sessions in db's sessions table, deleting expired ones every
+// cleanupInterval, or never if it is zero.
+func newSessionStore(db *models.DB, cleanupInterval time.Duration) scs.Store {
+	switch db.Dialect {
+	case models.Postgres:
+		return postgresstore.NewWithCleanupInterval(db.DB, cleanupInterval)
+	case models.SQLite:
+		return sqlite3store.NewWithCleanupInterval(db.DB, cleanupInterval)
+	default:
+		return mysqlstore.NewWithCleanupInterval(db.DB, cleanupInterval)
 	}
-	if err = db.Ping(); err != nil {
-		return nil, err
+}
+
+// lifetimeFlag returns a flag.Func which parses a snippet lifetime, such as 30m or 7d, into d.
+func lifetimeFlag(d *time.Duration) func(string) error {
+	return func(s string) error {
+		lifetime, never, err := expiry.Parse(s)
+		if err != nil || never {
+			return fmt.Errorf("invalid lifetime %q", s)
+		}
+		*d = lifetime
+		return nil
 	}
-	return db, nil
 }
 
 // TODO: Change input elements in signup and create to button elements
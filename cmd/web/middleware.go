@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/rlr524/snippetboxv2/internal/models"
 	"net/http"
 )
 
@@ -43,3 +46,61 @@ func (app *Application) recoverPanic(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// The requireAuthentication middleware redirects any request from an unauthenticated user to the login page. The
+// originally requested URL is stored in the session so that the user can be sent back there after logging in.
+func (app *Application) requireAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// If the user is not authenticated, remember the path they were trying to reach and redirect them to the
+		// login page. Only GET requests are remembered, as redirecting back to a POST-only route would 404.
+		if !app.isAuthenticated(r) {
+			if r.Method == http.MethodGet {
+				app.sessionManager.Put(r.Context(), "redirectPathAfterLogin", r.URL.RequestURI())
+			}
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+
+		// Otherwise set the "Cache-Control: no-store" header so that pages which require authentication are
+		// not stored in the user's browser cache (or any other intermediary cache).
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The authenticate middleware resolves the authenticatedUserID in the session against the database on every
+// request. If the user no longer exists or has been deactivated, the ID is removed from the session so the request
+// is treated as anonymous. Otherwise, the full *models.User is stored in the request context.
+func (app *Application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Retrieve the authenticatedUserID value from the session. If it isn't present, call the next handler in
+		// the chain as normal.
+		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		if id == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Otherwise, check whether a user with that ID exists in the database.
+		user, err := app.users.Get(id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+
+		// If the user has been deleted or deactivated, log them out by removing their ID from the session.
+		if user == nil || user.Active != 1 {
+			app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Create a copy of the request with the authentication status and the user added to the request context.
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserContextKey, user)
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+	})
+}
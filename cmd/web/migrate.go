@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"log"
+	"time"
+)
+
+// migrate runs the -migrate command against db: up applies every pending migration, down reverts the latest one, and
+// status lists every migration and whether it has been applied.
+func migrate(db *models.DB, command string, infoLog *log.Logger) error {
+	switch command {
+	case "up":
+		done, err := db.MigrateUp()
+		for _, m := range done {
+			infoLog.Printf("Applied migration %04d %s", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			infoLog.Print("No migrations to apply")
+		}
+		return err
+	case "down":
+		m, err := db.MigrateDown()
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			infoLog.Print("No migrations to revert")
+		} else {
+			infoLog.Printf("Reverted migration %04d %s", m.Version, m.Name)
+		}
+		return nil
+	case "status":
+		statuses, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			switch {
+			case s.Unknown():
+				infoLog.Printf("Migration %04d applied %s by a newer version", s.Version,
+					s.Applied.Format(time.DateTime))
+			case s.Pending():
+				infoLog.Printf("Migration %04d %s pending", s.Version, s.Name)
+			default:
+				infoLog.Printf("Migration %04d %s applied %s", s.Version, s.Name, s.Applied.Format(time.DateTime))
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+}
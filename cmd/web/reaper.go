@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// reaperConfig controls the background worker which purges expired snippets and sessions.
+type reaperConfig struct {
+	// interval is the time between purges. Zero disables the background worker.
+	interval time.Duration
+	// batchSize is the number of snippets removed per transaction, so a large backlog doesn't hold locks for long.
+	batchSize int
+	// archive copies expired snippets into the snippets_archive table instead of discarding them.
+	archive bool
+}
+
+// purgeExpired removes every expired snippet, one batch at a time, and then every expired session, and returns how
+// many of each were removed. It stops early, returning the context's error, if ctx is cancelled between batches.
+func (app *Application) purgeExpired(ctx context.Context) (snippets, sessions int, err error) {
+	for {
+		n, err := app.snippets.PurgeExpired(app.cfg.reaper.batchSize, app.cfg.reaper.archive)
+		snippets += n
+		if err != nil {
+			return snippets, 0, err
+		}
+		if n < app.cfg.reaper.batchSize {
+			break
+		}
+
+		if err := ctx.Err(); err != nil {
+			return snippets, 0, err
+		}
+	}
+
+	sessions, err = app.sessions.DeleteExpired()
+	return snippets, sessions, err
+}
+
+// logPurge reports the result of purgeExpired through the info log.
+func (app *Application) logPurge(snippets, sessions int, elapsed time.Duration) {
+	action := "Deleted"
+	if app.cfg.reaper.archive {
+		action = "Archived"
+	}
+	app.infoLog.Printf("%s %d expired snippets and deleted %d expired sessions in %s", action, snippets, sessions,
+		elapsed.Round(time.Millisecond))
+}
+
+// runReaper calls purgeExpired straight away and then every interval, until ctx is cancelled. Errors are logged
+// rather than stopping the worker, so a database outage only delays the next purge.
+func (app *Application) runReaper(ctx context.Context, interval time.Duration) {
+	app.infoLog.Printf("Reaper started, purging expired snippets every %s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		snippets, sessions, err := app.purgeExpired(ctx)
+		if err != nil && ctx.Err() == nil {
+			app.logError(err)
+		}
+		// Only log purges which did something, to keep the log quiet when nothing has expired.
+		if snippets > 0 || sessions > 0 {
+			app.logPurge(snippets, sessions, time.Since(start))
+		}
+
+		select {
+		case <-ctx.Done():
+			app.infoLog.Print("Reaper stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
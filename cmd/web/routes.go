@@ -1,9 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
 	"net/http"
+	"strings"
 )
 
 // The Routes method instantiates a new ServeMux from the net/http package, sets the static file server directory,
@@ -11,11 +13,26 @@ import (
 func (app *Application) Routes() http.Handler {
 	r := httprouter.New()
 
-	// The NotFound handler takes in a closure that returns the custom notFound message
+	// The NotFound handler takes in a closure that returns the custom notFound message, or a JSON error for
+	// requests to the API.
 	r.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiError(w, http.StatusNotFound, "the requested resource could not be found")
+			return
+		}
 		app.notFound(w)
 	})
 
+	// httprouter sets the Allow header before calling the MethodNotAllowed handler.
+	r.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiError(w, http.StatusMethodNotAllowed,
+				fmt.Sprintf("the %s method is not supported for this resource", r.Method))
+			return
+		}
+		app.clientError(w, http.StatusMethodNotAllowed)
+	})
+
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	// StripPrefix is added here as middleware to remove /static from the /static/ routes and
 	// hand them over to the neuteredFileSystem() method to disallow traversing of the static directory.
@@ -24,20 +41,61 @@ func (app *Application) Routes() http.Handler {
 		http.StripPrefix("/static",
 			app.neuteredFileSystem(fileServer)))
 
-	dynamic := alice.New(app.sessionManager.LoadAndSave)
+	// Middleware chain for dynamic routes. verifyCSRF must come after LoadAndSave, as the CSRF token is stored in
+	// the session.
+	dynamic := alice.New(app.sessionManager.LoadAndSave, app.verifyCSRF, app.authenticate)
 
 	// Home and Snippet routes
 	r.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
+	r.Handler(http.MethodGet, "/search", dynamic.ThenFunc(app.search))
+	r.Handler(http.MethodGet, "/tags", dynamic.ThenFunc(app.tagList))
+	r.Handler(http.MethodGet, "/tag/:name", dynamic.ThenFunc(app.tagView))
 	r.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
-	r.Handler(http.MethodGet, "/snippet/create", dynamic.ThenFunc(app.snippetCreate))
-	r.Handler(http.MethodPost, "/snippet/create", dynamic.ThenFunc(app.snippetCreatePost))
+	r.Handler(http.MethodPost, "/snippet/view/:id", dynamic.ThenFunc(app.snippetViewPost))
+	r.Handler(http.MethodGet, "/snippet/view/:id/history", dynamic.ThenFunc(app.snippetHistory))
+	r.Handler(http.MethodGet, "/snippet/raw/:id", dynamic.ThenFunc(app.snippetRaw))
+	r.Handler(http.MethodGet, "/snippet/download/:id", dynamic.ThenFunc(app.snippetDownload))
+	r.Handler(http.MethodGet, "/snippet/view/:id/diff", dynamic.ThenFunc(app.snippetDiff))
 
 	// User signup, login and logout routes
 	r.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
 	r.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
 	r.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
 	r.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
-	r.Handler(http.MethodPost, "/user/logout", dynamic.ThenFunc(app.userLogoutPost))
+
+	// Protected (authenticated-only) routes use the dynamic middleware chain followed by the
+	// requireAuthentication middleware.
+	protected := dynamic.Append(app.requireAuthentication)
+
+	r.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
+	r.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	r.Handler(http.MethodGet, "/snippet/edit/:id", protected.ThenFunc(app.snippetEdit))
+	r.Handler(http.MethodPost, "/snippet/edit/:id", protected.ThenFunc(app.snippetEditPost))
+	r.Handler(http.MethodPost, "/snippet/delete/:id", protected.ThenFunc(app.snippetDeletePost))
+	r.Handler(http.MethodPost, "/snippet/extend/:id", protected.ThenFunc(app.snippetExtendPost))
+	r.Handler(http.MethodPost, "/snippet/restore/:id", protected.ThenFunc(app.snippetRestorePost))
+	r.Handler(http.MethodGet, "/user/snippets", protected.ThenFunc(app.userSnippets))
+	r.Handler(http.MethodGet, "/user/account", protected.ThenFunc(app.userAccount))
+	r.Handler(http.MethodPost, "/user/tokens", protected.ThenFunc(app.userTokenCreatePost))
+	r.Handler(http.MethodPost, "/user/tokens/revoke/:id", protected.ThenFunc(app.userTokenRevokePost))
+	r.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
+
+	// The JSON API shares the session with the HTML pages, but isn't protected by verifyCSRF. Instead, readJSON
+	// requires an application/json request body, which a cross-site form can't send. Non-browser clients
+	// authenticate with a personal API token instead of the session, and tokens with the read-only scope are
+	// rejected by requireWriteScope on routes which change data.
+	api := alice.New(app.sessionManager.LoadAndSave, app.authenticate, app.authenticateToken)
+	apiProtected := api.Append(app.requireAPIAuthentication)
+	apiWrite := apiProtected.Append(app.requireWriteScope)
+
+	r.Handler(http.MethodGet, "/api/v1/snippets", api.ThenFunc(app.apiSnippetList))
+	r.Handler(http.MethodGet, "/api/v1/snippets/:id", api.ThenFunc(app.apiSnippetGet))
+	r.Handler(http.MethodPost, "/api/v1/snippets/:id/read", api.ThenFunc(app.apiSnippetRead))
+	r.Handler(http.MethodPost, "/api/v1/snippets", apiWrite.ThenFunc(app.apiSnippetCreate))
+	r.Handler(http.MethodPut, "/api/v1/snippets/:id", apiWrite.ThenFunc(app.apiSnippetUpdate))
+	r.Handler(http.MethodDelete, "/api/v1/snippets/:id", apiWrite.ThenFunc(app.apiSnippetDelete))
+	r.Handler(http.MethodGet, "/api/v1/search", api.ThenFunc(app.apiSearch))
+	r.Handler(http.MethodGet, "/api/v1/me", apiProtected.ThenFunc(app.apiMe))
 
 	// Middleware chain containing the standard middleware which is used for every request
 	standard := alice.New(app.recoverPanic, app.logRequests, secureHeaders)
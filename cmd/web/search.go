@@ -0,0 +1,154 @@
+package main
+
+import (
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"github.com/rlr524/snippetboxv2/internal/validator"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dateLayout is the format of the from and to search parameters, which matches the value of an HTML date input.
+const dateLayout = "2006-01-02"
+
+type searchForm struct {
+	Q                   string `form:"q"`
+	Author              string `form:"author"`
+	Tag                 string `form:"tag"`
+	From                string `form:"from"`
+	To                  string `form:"to"`
+	validator.Validator `form:"-"`
+}
+
+// The readSearch helper decodes and validates the search parameters from the query string, and returns them as
+// models.SearchFilters. The returned form carries any validation errors.
+func (app *Application) readSearch(r *http.Request) (searchForm, models.SearchFilters, error) {
+	var form searchForm
+
+	page, err := readInt(r, "page", 1, 1)
+	if err != nil {
+		return form, models.SearchFilters{}, err
+	}
+
+	err = app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		return form, models.SearchFilters{}, err
+	}
+
+	form.CheckField(validator.MaxChars(form.Q, 200), "q", "This field cannot be more than 200 characters long")
+	form.CheckField(validator.MaxChars(form.Author, 255), "author",
+		"This field cannot be more than 255 characters long")
+	form.CheckField(form.Tag == "" || validator.Matches(strings.ToLower(form.Tag), validator.TagRX), "tag",
+		"This field must be a valid tag")
+
+	filters := models.SearchFilters{
+		Query:    form.Q,
+		Author:   form.Author,
+		Tag:      strings.ToLower(form.Tag),
+		Page:     page,
+		PageSize: app.cfg.pageSize,
+	}
+
+	if form.From != "" {
+		filters.From, err = time.Parse(dateLayout, form.From)
+		form.CheckField(err == nil, "from", "This field must be a date in the format YYYY-MM-DD")
+	}
+	if form.To != "" {
+		filters.To, err = time.Parse(dateLayout, form.To)
+		form.CheckField(err == nil, "to", "This field must be a date in the format YYYY-MM-DD")
+	}
+
+	return form, filters, nil
+}
+
+// searchTerms returns the text of each term in a search query, for highlighting matches in the results.
+func searchTerms(q string) []string {
+	var terms []string
+	for _, t := range models.ParseSearchQuery(q) {
+		terms = append(terms, t.Text)
+	}
+	return terms
+}
+
+/*
+description: Search the active snippets by title and content, optionally filtered by author, tag and creation date
+route: /search
+method: GET
+*/
+func (app *Application) search(w http.ResponseWriter, r *http.Request) {
+	form, filters, err := app.readSearch(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	if !form.Valid() {
+		app.render(w, http.StatusUnprocessableEntity, "search.go.html", data)
+		return
+	}
+
+	// Only run the search once the user has asked for something, rather than listing every snippet.
+	if form.Q != "" || form.Author != "" || form.Tag != "" || form.From != "" || form.To != "" {
+		snippets, metadata, err := app.snippets.Search(filters)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		err = app.tags.Attach(snippets...)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		data.Snippets = snippets
+		data.Metadata = metadata
+		data.SearchTerms = searchTerms(form.Q)
+		data.Searched = true
+	}
+
+	app.render(w, http.StatusOK, "search.go.html", data)
+}
+
+/*
+description: Search the active snippets, taking the same q, author, tag, from, to and page parameters as /search
+route: /api/v1/search
+method: GET
+*/
+func (app *Application) apiSearch(w http.ResponseWriter, r *http.Request) {
+	form, filters, err := app.readSearch(r)
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !form.Valid() {
+		app.writeJSON(w, http.StatusUnprocessableEntity, apiErrorResponse{
+			Error:  "the search parameters failed validation",
+			Fields: form.FieldErrors,
+		})
+		return
+	}
+
+	snippets, metadata, err := app.snippets.Search(filters)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	err = app.tags.Attach(snippets...)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	out := make([]apiSnippet, 0, len(snippets))
+	for _, s := range snippets {
+		out = append(out, newAPISnippet(s))
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippets": out, "metadata": metadata})
+}
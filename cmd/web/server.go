@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serve starts the background workers and the HTTPS server, and blocks until the server stops. On SIGINT or SIGTERM
+// (which nodemon sends on every reload) it stops accepting connections, lets in-flight requests finish, stops the
+// background workers and waits for them, giving up after cfg.shutdownTimeout. It returns nil after a clean
+// shutdown, and otherwise the error which stopped the server.
+func (app *Application) serve(srv *http.Server) error {
+	// bgCtx is cancelled to tell the background workers to stop.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	if app.cfg.reaper.interval > 0 {
+		app.background(func() {
+			app.runReaper(bgCtx, app.cfg.reaper.interval)
+		})
+	}
+
+	// shutdownErr receives the result of the shutdown once a signal has been handled.
+	shutdownErr := make(chan error)
+	// reason records why the server was shut down, for the final log line.
+	var reason os.Signal
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		reason = <-quit
+		signal.Stop(quit)
+
+		app.infoLog.Printf("Caught signal %q, shutting down server", reason)
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.cfg.shutdownTimeout)
+		defer cancel()
+
+		// Shutdown closes the listeners, so ListenAndServeTLS returns http.ErrServerClosed straight away, and then
+		// waits for in-flight requests to finish or for ctx to expire.
+		err := srv.Shutdown(ctx)
+
+		stopBackground()
+		waitErr := app.waitBackground(ctx)
+
+		shutdownErr <- errors.Join(err, waitErr)
+	}()
+
+	app.infoLog.Printf("Starting server on %s", srv.Addr)
+	err := srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+	if !errors.Is(err, http.ErrServerClosed) {
+		// The server failed rather than being shut down, so stop the background workers before giving up.
+		stopBackground()
+		_ = app.waitBackground(context.Background())
+		return err
+	}
+
+	start := time.Now()
+	err = <-shutdownErr
+	if err != nil {
+		return fmt.Errorf("shutdown after signal %q: %w", reason, err)
+	}
+
+	app.infoLog.Printf("Server stopped after signal %q; shutdown took %s", reason,
+		time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// background runs fn in a new goroutine which shutdown waits for. A panic in fn is logged rather than crashing the
+// server, as there is no request for recoverPanic to report it against.
+func (app *Application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logError(fmt.Errorf("background panic: %v", err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// waitBackground waits for every goroutine started with background to return, or for ctx to expire.
+func (app *Application) waitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		app.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background workers did not stop: %w", ctx.Err())
+	}
+}
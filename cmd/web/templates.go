@@ -1,10 +1,19 @@
 package main
 
 import (
+	"fmt"
+	"github.com/rlr524/snippetboxv2/internal/diff"
+	"github.com/rlr524/snippetboxv2/internal/expiry"
+	"github.com/rlr524/snippetboxv2/internal/highlight"
 	"github.com/rlr524/snippetboxv2/internal/models"
+	"html/template"
+	"net/url"
 	"path/filepath"
-	"text/template"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // TemplateData acts as the holding structure for any dynamic data that is passed to the html templates.
@@ -16,6 +25,39 @@ type TemplateData struct {
 	Flash           string
 	IsAuthenticated bool
 	User            *models.User
+	Revisions       []*models.SnippetRevision
+	Diff            *DiffData
+	Tokens          []*models.Token
+	NewToken        string
+	Metadata        models.Metadata
+	CurrentURL      *url.URL
+	SearchTerms     []string
+	Searched        bool
+	Tag             string
+	Tags            []*models.Tag
+	ExpiryPresets   []expiry.Preset
+	// csrfToken returns the session's CSRF token, creating it if need be. It is called through CSRFToken.
+	csrfToken func() (string, error)
+}
+
+// CSRFToken returns the CSRF token to include as a hidden field in a form. The token is only created when a page
+// with a form is rendered, so that pages without one don't start a session for anonymous visitors.
+func (d TemplateData) CSRFToken() (string, error) {
+	if d.csrfToken == nil {
+		return "", nil
+	}
+	return d.csrfToken()
+}
+
+// DiffData holds the comparison between two revisions of a snippet for the diff page. Lines is used for the unified
+// layout and Rows for the split (side-by-side) layout.
+type DiffData struct {
+	From    *models.SnippetRevision
+	To      *models.SnippetRevision
+	Mode    string
+	Changed bool
+	Lines   []diff.Line
+	Rows    []diff.Row
 }
 
 // The humanDate() function returns a formatted string representation of a time.Time object.
@@ -23,10 +65,138 @@ func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// The humanExpiry() function returns how long is left until the expiry time t, rounded down to a whole number of the
+// largest unit which fits, such as "in 3 days". It returns "never" for snippets which never expire, and "expired"
+// once t has passed.
+func humanExpiry(t time.Time) string {
+	if !t.Before(models.NeverExpires) {
+		return "never"
+	}
+
+	remaining := time.Until(t)
+	switch {
+	case remaining <= 0:
+		return "expired"
+	case remaining < time.Minute:
+		return "in less than a minute"
+	}
+
+	for _, u := range []struct {
+		unit time.Duration
+		name string
+	}{
+		{365 * 24 * time.Hour, "year"},
+		{24 * time.Hour, "day"},
+		{time.Hour, "hour"},
+		{time.Minute, "minute"},
+	} {
+		if n := int(remaining / u.unit); n > 0 {
+			if n == 1 {
+				return "in 1 " + u.name
+			}
+			return fmt.Sprintf("in %d %ss", n, u.name)
+		}
+	}
+	return "expired"
+}
+
+// The pageURL() function returns the given URL with its page query string parameter set to page, keeping any other
+// parameters so that filtered listings stay filtered as the user moves between pages.
+func pageURL(u *url.URL, page int) string {
+	if u == nil {
+		u = &url.URL{Path: "/"}
+	}
+	query := u.Query()
+	query.Set("page", strconv.Itoa(page))
+	return (&url.URL{Path: u.Path, RawQuery: query.Encode()}).String()
+}
+
+// excerptRunes is the approximate length of the excerpt of a snippet's content shown in search results.
+const excerptRunes = 200
+
+// termsPattern returns a case-insensitive regular expression matching any of the given search terms, or nil if there
+// are no terms.
+func termsPattern(terms []string) *regexp.Regexp {
+	var quoted []string
+	for _, t := range terms {
+		if t != "" {
+			quoted = append(quoted, regexp.QuoteMeta(t))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+// The excerpt() function returns about excerptRunes characters of text, centred on the first match of any of the
+// search terms, with an ellipsis wherever the text has been cut.
+func excerpt(text string, terms []string) string {
+	runes := []rune(text)
+	if len(runes) <= excerptRunes {
+		return text
+	}
+
+	start := 0
+	if rx := termsPattern(terms); rx != nil {
+		if loc := rx.FindStringIndex(text); loc != nil {
+			// Convert the byte offset of the match into a rune offset, and start a little before it.
+			start = max(utf8.RuneCountInString(text[:loc[0]])-excerptRunes/4, 0)
+		}
+	}
+	end := min(start+excerptRunes, len(runes))
+	start = max(end-excerptRunes, 0)
+
+	out := string(runes[start:end])
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(runes) {
+		out += "…"
+	}
+	return out
+}
+
+// The markTerms() function HTML-escapes text and wraps every match of the search terms in a <mark> element. It
+// returns template.HTML because the markup is built here from escaped pieces and must not be escaped again.
+func markTerms(text string, terms []string) template.HTML {
+	rx := termsPattern(terms)
+	if rx == nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range rx.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+
+	return template.HTML(b.String())
+}
+
+// The templates are parsed with html/template, so every value is escaped according to the context (HTML, attribute,
+// URL, JavaScript) it is rendered in. Markup which is intentionally trusted must be built in Go code and passed to the
+// templates using one of the explicit safe types such as template.HTML or template.URL. User-supplied content must
+// never be converted to one of these types.
+
 // Init a template.FuncMap object and store as a global var. This is essentially a string-keyed
 // map which acts as a lookup between the names of the custom template functions and the functions themselves.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":   humanDate,
+	"humanExpiry": humanExpiry,
+	"pageURL":     pageURL,
+	"excerpt":     excerpt,
+	"markTerms":   markTerms,
+	"join":        strings.Join,
+	// The syntax function renders snippet content with syntax highlighting, line numbers and line anchors.
+	"syntax":       highlight.HTML,
+	"languages":    highlight.Languages,
+	"languageName": highlight.Name,
 }
 
 // The newTemplateCache() function creates a map for a template cache, loops over all
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"github.com/rlr524/snippetboxv2/internal/diff"
+	"github.com/rlr524/snippetboxv2/internal/expiry"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"github.com/rlr524/snippetboxv2/internal/validator"
+	"html/template"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain changes into the repository root before running the tests, as the template cache and the static file
+// server use paths relative to the directory the application is started from.
+func TestMain(m *testing.M) {
+	err := os.Chdir("../..")
+	if err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestPageURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		page int
+		want string
+	}{
+		{"No query", "/", 2, "/?page=2"},
+		{"Replaces page", "/user/snippets?page=5", 4, "/user/snippets?page=4"},
+		{"Keeps other parameters", "/search?q=go+http&page=1", 2, "/search?page=2&q=go+http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := pageURL(u, tt.page)
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  template.HTML
+	}{
+		{"No terms", "<b>go</b>", nil, "&lt;b&gt;go&lt;/b&gt;"},
+		{"Case insensitive", "Go and go", []string{"GO"}, "<mark>Go</mark> and <mark>go</mark>"},
+		{"Escapes matches", "a <script> b", []string{"<script>"}, "a <mark>&lt;script&gt;</mark> b"},
+		{"Regexp characters", "f(x) + g(x)", []string{"(x)"}, "f<mark>(x)</mark> + g<mark>(x)</mark>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := markTerms(tt.text, tt.terms)
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHumanDate(t *testing.T) {
+	tests := []struct {
+		name string
+		tm   time.Time
+		want string
+	}{
+		{
+			name: "UTC",
+			tm:   time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC),
+			want: "17 Mar 2024 at 10:15",
+		},
+		{
+			name: "Empty",
+			tm:   time.Time{},
+			want: "01 Jan 0001 at 00:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := humanDate(tt.tm)
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHumanExpiry(t *testing.T) {
+	tests := []struct {
+		name string
+		tm   time.Time
+		want string
+	}{
+		{"Never", models.NeverExpires, "never"},
+		{"Expired", time.Now().Add(-time.Minute), "expired"},
+		{"Seconds", time.Now().Add(30 * time.Second), "in less than a minute"},
+		{"Minutes", time.Now().Add(5*time.Minute + 30*time.Second), "in 5 minutes"},
+		{"OneHour", time.Now().Add(time.Hour + time.Minute), "in 1 hour"},
+		{"Days", time.Now().Add(3*24*time.Hour + time.Hour), "in 3 days"},
+		{"Years", time.Now().Add(2*365*24*time.Hour + time.Hour), "in 2 years"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := humanExpiry(tt.tm)
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// hostile is a payload which would execute script if it were rendered without escaping, whether it ends up in
+// element content, an attribute value or a textarea.
+const hostile = `"></textarea><script>alert('xss')</script><img src=x onerror=alert(1)>`
+
+// TestTemplatesEscapeHostileInput renders user-controlled data through every page in the template cache and checks
+// that none of it comes out as live markup.
+func TestTemplatesEscapeHostileInput(t *testing.T) {
+	cache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippet := &models.Snippet{
+		ID:        1,
+		Title:     hostile,
+		Content:   hostile,
+		Created:   time.Now(),
+		Expires:   time.Now().Add(24 * time.Hour),
+		CreatedBy: models.User{ID: 1, Name: hostile},
+		Tags:      []string{hostile},
+		// Unlisted, so that the slug is rendered into every snippet URL.
+		Visibility: models.VisibilityUnlisted,
+		Slug:       hostile,
+	}
+	revision := &models.SnippetRevision{
+		ID:        1,
+		SnippetID: 1,
+		Revision:  1,
+		Title:     hostile,
+		Content:   hostile,
+		Created:   time.Now(),
+		CreatedBy: models.User{ID: 1, Name: hostile},
+	}
+	presets := expiry.DefaultPolicy.Presets()
+	lines := diff.Lines("safe\n"+hostile, hostile+"\nsafe")
+	unified := &DiffData{From: revision, To: revision, Mode: "unified", Changed: true, Lines: lines}
+	split := &DiffData{From: revision, To: revision, Mode: "split", Changed: true, Rows: diff.SideBySide(lines)}
+	user := &models.User{ID: 1, Name: hostile, Email: "alice@example.com", Active: 1}
+
+	tests := []struct {
+		page string
+		data *TemplateData
+	}{
+		{"home.go.html", &TemplateData{
+			Snippets:   []*models.Snippet{snippet},
+			Metadata:   models.Metadata{CurrentPage: 2, PageSize: 1, LastPage: 3, TotalRecords: 3},
+			CurrentURL: &url.URL{Path: "/", RawQuery: url.Values{"q": {hostile}}.Encode()},
+		}},
+		{"view.go.html", &TemplateData{Snippet: snippet}},
+		{"view.go.html", &TemplateData{Snippet: snippet, User: &models.User{ID: 1, Name: hostile}, ExpiryPresets: presets,
+			Form: snippetExtendForm{Validator: validator.Validator{FieldErrors: map[string]string{"expires": hostile}}}}},
+		{"burn.go.html", &TemplateData{Snippet: snippet, User: &models.User{ID: 1, Name: hostile}}},
+		{"read.go.html", &TemplateData{Snippet: snippet}},
+		{"snippets.go.html", &TemplateData{Snippets: []*models.Snippet{snippet}}},
+		{"create.go.html", &TemplateData{ExpiryPresets: presets, Form: snippetCreateForm{
+			Title: hostile, Content: hostile, Expires: customExpiry, ExpiresCustom: hostile, Tags: []string{hostile},
+		}}},
+		{"edit.go.html", &TemplateData{Snippet: snippet, ExpiryPresets: presets, Form: snippetCreateForm{
+			Title: hostile, Content: hostile, Expires: "1w",
+		}}},
+		{"history.go.html", &TemplateData{Snippet: snippet, Revisions: []*models.SnippetRevision{revision, revision}}},
+		{"diff.go.html", &TemplateData{Snippet: snippet, Diff: unified}},
+		{"diff.go.html", &TemplateData{Snippet: snippet, Diff: split}},
+		{"account.go.html", &TemplateData{
+			Form:     tokenCreateForm{Name: hostile, Scope: models.ScopeRead},
+			Tokens:   []*models.Token{{ID: 1, Name: hostile, Scope: models.ScopeRead}},
+			NewToken: hostile,
+		}},
+		{"search.go.html", &TemplateData{
+			Form:        searchForm{Q: hostile, Author: hostile},
+			Snippets:    []*models.Snippet{snippet},
+			SearchTerms: searchTerms("script " + hostile),
+			Searched:    true,
+		}},
+		{"tags.go.html", &TemplateData{Tags: []*models.Tag{{ID: 1, Name: hostile, Count: 1}}}},
+		{"tag.go.html", &TemplateData{Tag: hostile, Snippets: []*models.Snippet{snippet}}},
+		{"signup.go.html", &TemplateData{Form: userSignUpForm{Name: hostile, Email: hostile}}},
+		{"login.go.html", &TemplateData{Form: userLoginForm{Email: hostile}}},
+		{"csrf.go.html", &TemplateData{}},
+	}
+
+	// Every page must be covered, so that a newly added page can't skip the escaping check.
+	for name := range cache {
+		found := false
+		for _, tt := range tests {
+			if tt.page == name {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("page %s is not covered by the escaping tests", name)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			ts, ok := cache[tt.page]
+			if !ok {
+				t.Fatalf("page %s is not in the template cache", tt.page)
+			}
+
+			// Render every page as an authenticated user with a hostile name and flash message, so that the
+			// base layout and the nav partial are checked too.
+			tt.data.IsAuthenticated = true
+			tt.data.User = user
+			tt.data.Flash = hostile
+			tt.data.csrfToken = func() (string, error) {
+				return hostile, nil
+			}
+
+			buf := new(bytes.Buffer)
+			err := ts.ExecuteTemplate(buf, "base", tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := buf.String()
+
+			for _, raw := range []string{"<script>", "<img", "</textarea><"} {
+				if strings.Contains(body, raw) {
+					t.Errorf("rendered page contains unescaped %q", raw)
+				}
+			}
+
+			if !strings.Contains(body, "&lt;script&gt;") {
+				t.Errorf("rendered page does not contain the escaped payload")
+			}
+		})
+	}
+}
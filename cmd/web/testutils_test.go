@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-playground/form/v4"
+	"github.com/rlr524/snippetboxv2/internal/expiry"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"github.com/rlr524/snippetboxv2/internal/models/mocks"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestApplication returns an Application backed by the in-memory mocks, along with the mocks so that tests can
+// add data to them and check what the handlers did. The logs are discarded.
+func newTestApplication(t *testing.T) (*Application, *mocks.Store) {
+	t.Helper()
+
+	templateCache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The session manager uses its default in-memory store.
+	sessionManager := scs.New()
+	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Cookie.Secure = true
+
+	store := mocks.NewStore()
+
+	app := &Application{
+		errorLog:       log.New(io.Discard, "", 0),
+		infoLog:        log.New(io.Discard, "", 0),
+		cfg:            Config{pageSize: 10, expiry: expiry.DefaultPolicy},
+		snippets:       store.Snippets,
+		users:          store.Users,
+		revisions:      store.Revisions,
+		tokens:         store.Tokens,
+		tags:           store.Tags,
+		sessions:       store.Sessions,
+		templateCache:  templateCache,
+		formDecoder:    form.NewDecoder(),
+		sessionManager: sessionManager,
+	}
+	return app, store
+}
+
+// testServer is an HTTPS test server with a client which keeps cookies between requests, so that a test can act
+// as a single browser.
+type testServer struct {
+	*httptest.Server
+}
+
+// newTestServer starts a test server for the given handler, which is closed when the test finishes.
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	t.Helper()
+
+	ts := httptest.NewTLSServer(h)
+	t.Cleanup(ts.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+
+	// Return redirects to the test rather than following them, so that the status and Location can be checked.
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testServer{ts}
+}
+
+// testResponse is the part of a response checked by the tests.
+type testResponse struct {
+	status int
+	header http.Header
+	body   string
+}
+
+// do sends a request to the test server and returns the response.
+func (ts *testServer) do(t *testing.T, method, urlPath, contentType string, body io.Reader,
+	header http.Header) testResponse {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+urlPath, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(rs.Body)
+
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testResponse{status: rs.StatusCode, header: rs.Header, body: string(bytes.TrimSpace(b))}
+}
+
+// get sends a GET request to the test server.
+func (ts *testServer) get(t *testing.T, urlPath string) testResponse {
+	t.Helper()
+	return ts.do(t, http.MethodGet, urlPath, "", nil, nil)
+}
+
+// postForm sends a form to the test server, adding the session's CSRF token.
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) testResponse {
+	t.Helper()
+
+	values := url.Values{}
+	for k, v := range form {
+		values[k] = v
+	}
+	values.Set(csrfTokenField, ts.csrfToken(t))
+
+	return ts.do(t, http.MethodPost, urlPath, "application/x-www-form-urlencoded",
+		strings.NewReader(values.Encode()), nil)
+}
+
+// csrfTokenRX matches the hidden CSRF token field included in every form.
+var csrfTokenRX = regexp.MustCompile(`<input type="hidden" name="csrf_token" value="([^"]+)">`)
+
+// csrfToken returns the CSRF token of the test client's session, by reading it from the signup form.
+func (ts *testServer) csrfToken(t *testing.T) string {
+	t.Helper()
+
+	rs := ts.get(t, "/user/signup")
+	matches := csrfTokenRX.FindStringSubmatch(rs.body)
+	if len(matches) < 2 {
+		t.Fatal("no CSRF token found in the signup form")
+	}
+	return html.UnescapeString(matches[1])
+}
+
+// login logs the test client in with the given credentials.
+func (ts *testServer) login(t *testing.T, email, password string) {
+	t.Helper()
+
+	rs := ts.postForm(t, "/user/login", url.Values{"email": {email}, "password": {password}})
+	if rs.status != http.StatusSeeOther {
+		t.Fatalf("logging in as %s: got status %d; want %d", email, rs.status, http.StatusSeeOther)
+	}
+}
+
+// testPassword is the password of every user created by addUser.
+const testPassword = "pa$$word"
+
+// addUser adds a user to the mocks and returns its ID.
+func addUser(t *testing.T, store *mocks.Store, name, email string) int {
+	t.Helper()
+
+	err := store.Users.Insert(name, email, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := store.Users.Authenticate(email, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// addSnippet adds a snippet which expires in a week to the mocks, along with its first revision, and returns it.
+func addSnippet(t *testing.T, store *mocks.Store, userID int, title, visibility string,
+	burnAfterReading bool) *models.Snippet {
+	t.Helper()
+
+	id, err := store.Snippets.Insert(title, "An old silent pond...", "", visibility,
+		time.Now().AddDate(0, 0, 7), userID, burnAfterReading, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := store.Snippets.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,197 @@
+// Package diff computes line-based differences between two versions of a text, for display as either a unified or
+// a side-by-side diff.
+package diff
+
+import "strings"
+
+// Op describes what happened to a line between the old and the new text.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// maxCells limits the size of the table used to find the longest common subsequence of lines. Texts which would
+// need a bigger table are shown as the whole old text deleted and the whole new text inserted, so that a pair of
+// huge snippets can't be used to exhaust the server's memory.
+const maxCells = 4_000_000
+
+// Line is a single line of a diff. OldNumber and NewNumber are the 1-based line numbers in the old and the new
+// text, and are 0 when the line doesn't appear in that text.
+type Line struct {
+	Op        Op
+	Text      string
+	OldNumber int
+	NewNumber int
+}
+
+// Prefix returns the marker used for the line in a unified diff.
+func (l Line) Prefix() string {
+	switch l.Op {
+	case Insert:
+		return "+"
+	case Delete:
+		return "-"
+	default:
+		return " "
+	}
+}
+
+// Class returns a CSS class name describing the line.
+func (l Line) Class() string {
+	switch l.Op {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// Row is a single row of a side-by-side diff. Left is nil when a line was only inserted, and Right is nil when a
+// line was only deleted.
+type Row struct {
+	Left  *Line
+	Right *Line
+}
+
+// Changed reports whether the diff contains any inserted or deleted lines.
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Lines returns the line-based difference between a and b, in the order the lines appear in a unified diff.
+func Lines(a, b string) []Line {
+	old := split(a)
+	cur := split(b)
+
+	// Lines shared at the start and end of both texts are always unchanged, so they're trimmed before building the
+	// table to keep it as small as possible.
+	prefix := 0
+	for prefix < len(old) && prefix < len(cur) && old[prefix] == cur[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(cur)-prefix &&
+		old[len(old)-1-suffix] == cur[len(cur)-1-suffix] {
+		suffix++
+	}
+
+	var lines []Line
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, Line{Op: Equal, Text: old[i], OldNumber: i + 1, NewNumber: i + 1})
+	}
+
+	lines = append(lines, middle(old[prefix:len(old)-suffix], cur[prefix:len(cur)-suffix], prefix, prefix)...)
+
+	for i := 0; i < suffix; i++ {
+		o := len(old) - suffix + i
+		n := len(cur) - suffix + i
+		lines = append(lines, Line{Op: Equal, Text: old[o], OldNumber: o + 1, NewNumber: n + 1})
+	}
+
+	return lines
+}
+
+// middle diffs the lines left after trimming the common prefix and suffix. oldOffset and newOffset are the number of
+// lines which were trimmed from the start of each text, so the line numbers can be corrected.
+func middle(old, cur []string, oldOffset, newOffset int) []Line {
+	n, m := len(old), len(cur)
+
+	var lines []Line
+
+	if n*m > maxCells {
+		for i, text := range old {
+			lines = append(lines, Line{Op: Delete, Text: text, OldNumber: oldOffset + i + 1})
+		}
+		for j, text := range cur {
+			lines = append(lines, Line{Op: Insert, Text: text, NewNumber: newOffset + j + 1})
+		}
+		return lines
+	}
+
+	// lcs[i*(m+1)+j] holds the length of the longest common subsequence of old[i:] and cur[j:].
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if old[i] == cur[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+
+	// Walk the table from the start, preferring deletions over insertions so that removed lines come before the
+	// lines which replaced them, as they do in a unified diff.
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && old[i] == cur[j]:
+			lines = append(lines, Line{Op: Equal, Text: old[i], OldNumber: oldOffset + i + 1, NewNumber: newOffset + j + 1})
+			i++
+			j++
+		case i < n && (j == m || lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]):
+			lines = append(lines, Line{Op: Delete, Text: old[i], OldNumber: oldOffset + i + 1})
+			i++
+		default:
+			lines = append(lines, Line{Op: Insert, Text: cur[j], NewNumber: newOffset + j + 1})
+			j++
+		}
+	}
+
+	return lines
+}
+
+// SideBySide arranges the lines of a unified diff into rows, pairing each run of deleted lines with the run of
+// inserted lines which follows it.
+func SideBySide(lines []Line) []Row {
+	var rows []Row
+
+	for k := 0; k < len(lines); {
+		if lines[k].Op == Equal {
+			rows = append(rows, Row{Left: &lines[k], Right: &lines[k]})
+			k++
+			continue
+		}
+
+		var deleted, inserted []*Line
+		for ; k < len(lines) && lines[k].Op == Delete; k++ {
+			deleted = append(deleted, &lines[k])
+		}
+		for ; k < len(lines) && lines[k].Op == Insert; k++ {
+			inserted = append(inserted, &lines[k])
+		}
+
+		for r := 0; r < max(len(deleted), len(inserted)); r++ {
+			var row Row
+			if r < len(deleted) {
+				row.Left = deleted[r]
+			}
+			if r < len(inserted) {
+				row.Right = inserted[r]
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// split breaks a text into lines, treating "\r\n" the same as "\n" since browsers submit textarea content with
+// Windows line endings. An empty text has no lines.
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,138 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Line
+	}{
+		{
+			name: "empty",
+			a:    "",
+			b:    "",
+			want: nil,
+		},
+		{
+			name: "identical",
+			a:    "one\ntwo\n",
+			b:    "one\ntwo\n",
+			want: []Line{
+				{Op: Equal, Text: "one", OldNumber: 1, NewNumber: 1},
+				{Op: Equal, Text: "two", OldNumber: 2, NewNumber: 2},
+			},
+		},
+		{
+			name: "insert only",
+			a:    "one\nthree\n",
+			b:    "one\ntwo\nthree\n",
+			want: []Line{
+				{Op: Equal, Text: "one", OldNumber: 1, NewNumber: 1},
+				{Op: Insert, Text: "two", NewNumber: 2},
+				{Op: Equal, Text: "three", OldNumber: 2, NewNumber: 3},
+			},
+		},
+		{
+			name: "insert into empty",
+			a:    "",
+			b:    "one\n",
+			want: []Line{
+				{Op: Insert, Text: "one", NewNumber: 1},
+			},
+		},
+		{
+			name: "delete only",
+			a:    "one\ntwo\nthree\n",
+			b:    "one\nthree\n",
+			want: []Line{
+				{Op: Equal, Text: "one", OldNumber: 1, NewNumber: 1},
+				{Op: Delete, Text: "two", OldNumber: 2},
+				{Op: Equal, Text: "three", OldNumber: 3, NewNumber: 2},
+			},
+		},
+		{
+			name: "mixed",
+			a:    "one\ntwo\nthree\nfour\n",
+			b:    "zero\none\n2\nthree\n",
+			want: []Line{
+				{Op: Insert, Text: "zero", NewNumber: 1},
+				{Op: Equal, Text: "one", OldNumber: 1, NewNumber: 2},
+				{Op: Delete, Text: "two", OldNumber: 2},
+				{Op: Insert, Text: "2", NewNumber: 3},
+				{Op: Equal, Text: "three", OldNumber: 3, NewNumber: 4},
+				{Op: Delete, Text: "four", OldNumber: 4},
+			},
+		},
+		{
+			name: "missing trailing newline",
+			a:    "one\ntwo",
+			b:    "one\ntwo\n",
+			want: []Line{
+				{Op: Equal, Text: "one", OldNumber: 1, NewNumber: 1},
+				{Op: Equal, Text: "two", OldNumber: 2, NewNumber: 2},
+			},
+		},
+		{
+			name: "windows line endings",
+			a:    "one\r\ntwo\r\n",
+			b:    "one\ntwo\n",
+			want: []Line{
+				{Op: Equal, Text: "one", OldNumber: 1, NewNumber: 1},
+				{Op: Equal, Text: "two", OldNumber: 2, NewNumber: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.a, tt.b)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+
+			changed := slices.ContainsFunc(tt.want, func(l Line) bool { return l.Op != Equal })
+			if Changed(got) != changed {
+				t.Errorf("got Changed %t; want %t", Changed(got), changed)
+			}
+		})
+	}
+}
+
+func TestLinesTooBig(t *testing.T) {
+	// Texts too big for the table are shown as deleted and inserted in full.
+	a := strings.Repeat("a\n", 2001)
+	b := strings.Repeat("b\n", 2001)
+
+	lines := Lines(a, b)
+	if len(lines) != 4002 || lines[0].Op != Delete || lines[2001].Op != Insert || lines[2001].NewNumber != 1 {
+		t.Errorf("got %d lines starting %+v; want 2001 deleted then 2001 inserted", len(lines), lines[0])
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	lines := Lines("one\ntwo\nthree\n", "one\n2\n3\nthree\n")
+	rows := SideBySide(lines)
+
+	// Each row is written as left|right, with - for a missing side.
+	var got []string
+	for _, r := range rows {
+		left, right := "-", "-"
+		if r.Left != nil {
+			left = r.Left.Text
+		}
+		if r.Right != nil {
+			right = r.Right.Text
+		}
+		got = append(got, left+"|"+right)
+	}
+
+	want := []string{"one|one", "two|2", "-|3", "three|three"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got rows %q; want %q", got, want)
+	}
+}
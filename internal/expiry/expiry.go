@@ -0,0 +1,171 @@
+// Package expiry parses snippet lifetimes such as "30m", "7d" or "never", and checks them against the policy set by
+// the administrator.
+package expiry
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Never is the lifetime of snippets which never expire.
+const Never = "never"
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+	year = 365 * day
+)
+
+// units maps the unit suffixes accepted by Parse to their durations. A year is always 365 days.
+var units = map[string]time.Duration{
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": day,
+	"w": week,
+	"y": year,
+}
+
+// unitNames is used by Describe, from the largest unit to the smallest.
+var unitNames = []struct {
+	unit time.Duration
+	name string
+}{
+	{year, "year"},
+	{week, "week"},
+	{day, "day"},
+	{time.Hour, "hour"},
+	{time.Minute, "minute"},
+}
+
+// ErrInvalid is returned by Parse if a lifetime isn't a positive whole number followed by a unit, or "never".
+var ErrInvalid = errors.New("expiry: invalid lifetime")
+
+// Parse parses a lifetime written as a whole number followed by one of the units m (minutes), h (hours), d (days),
+// w (weeks) or y (years of 365 days), such as "90m" or "2w", or the word "never". A number without a unit is a number
+// of days, which is how lifetimes were given before they could be anything other than 1, 7 or 365 days. For
+// "never", never is true and d is zero.
+func Parse(s string) (d time.Duration, never bool, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == Never {
+		return 0, true, nil
+	}
+
+	unit := day
+	if n := len(s); n > 0 {
+		if u, ok := units[s[n-1:]]; ok {
+			unit = u
+			s = s[:n-1]
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, false, ErrInvalid
+	}
+
+	// Reject lifetimes which would overflow a time.Duration.
+	if int64(n) > int64(1<<63-1)/int64(unit) {
+		return 0, false, ErrInvalid
+	}
+
+	return time.Duration(n) * unit, false, nil
+}
+
+// Describe returns a human-readable description of d, such as "3 days" or "1 hour", using the largest unit which
+// divides it exactly.
+func Describe(d time.Duration) string {
+	for _, u := range unitNames {
+		if d >= u.unit && d%u.unit == 0 {
+			return plural(int64(d/u.unit), u.name)
+		}
+	}
+	return d.String()
+}
+
+// plural returns n followed by name, with an "s" added unless n is 1.
+func plural(n int64, name string) string {
+	if n == 1 {
+		return "1 " + name
+	}
+	return fmt.Sprintf("%d %ss", n, name)
+}
+
+// Preset is one of the lifetimes offered as a choice on the create and edit snippet forms.
+type Preset struct {
+	Value string
+	Label string
+}
+
+// presets is every lifetime offered on the forms, shortest first. Policy.Presets filters out the ones the policy
+// doesn't allow.
+var presets = []Preset{
+	{"10m", "Ten minutes"},
+	{"1h", "One hour"},
+	{"1d", "One day"},
+	{"1w", "One week"},
+	{"30d", "30 days"},
+	{"1y", "One year"},
+	{Never, "Never"},
+}
+
+// Policy is the range of lifetimes the administrator allows snippets to have.
+type Policy struct {
+	// Min and Max are the shortest and longest lifetimes allowed. A Max of zero means there is no maximum.
+	Min time.Duration
+	Max time.Duration
+	// AllowNever is whether snippets are allowed to never expire.
+	AllowNever bool
+}
+
+// DefaultPolicy allows any lifetime from one minute up, including never.
+var DefaultPolicy = Policy{Min: time.Minute, AllowNever: true}
+
+// Check parses the lifetime s and checks that the policy allows it. The error is suitable for showing to the user.
+func (p Policy) Check(s string) (d time.Duration, never bool, err error) {
+	d, never, err = Parse(s)
+	if err != nil {
+		return 0, false, errors.New("This field must be a number followed by m, h, d, w or y, such as 30m or 7d")
+	}
+
+	switch {
+	case never && !p.AllowNever:
+		return 0, false, errors.New("Snippets must have an expiry")
+	case never:
+		return 0, true, nil
+	case d < p.Min:
+		return 0, false, fmt.Errorf("This field must be at least %s", Describe(p.Min))
+	case p.Max > 0 && d > p.Max:
+		return 0, false, fmt.Errorf("This field must be at most %s", Describe(p.Max))
+	}
+
+	return d, false, nil
+}
+
+// Presets returns the preset lifetimes which the policy allows, shortest first.
+func (p Policy) Presets() []Preset {
+	var allowed []Preset
+	for _, preset := range presets {
+		if _, _, err := p.Check(preset.Value); err == nil {
+			allowed = append(allowed, preset)
+		}
+	}
+	return allowed
+}
+
+// Default returns the lifetime pre-selected on the create snippet form: one year, or the longest preset the policy
+// allows if that's shorter. If the policy allows none of the presets it returns the minimum lifetime.
+func (p Policy) Default() string {
+	allowed := p.Presets()
+	for i := len(allowed) - 1; i >= 0; i-- {
+		if allowed[i].Value == Never {
+			continue
+		}
+		if d, _, _ := Parse(allowed[i].Value); d <= year {
+			return allowed[i].Value
+		}
+	}
+	return strconv.FormatInt(int64(p.Min/time.Minute), 10) + "m"
+}
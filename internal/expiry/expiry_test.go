@@ -0,0 +1,99 @@
+package expiry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  time.Duration
+		never bool
+		err   bool
+	}{
+		{in: "30m", want: 30 * time.Minute},
+		{in: "12h", want: 12 * time.Hour},
+		{in: "7d", want: 7 * day},
+		{in: "2w", want: 2 * week},
+		{in: "1y", want: year},
+		{in: " 3D ", want: 3 * day},
+		{in: "365", want: 365 * day},
+		{in: "never", never: true},
+		{in: "Never", never: true},
+		{in: "", err: true},
+		{in: "0d", err: true},
+		{in: "-1h", err: true},
+		{in: "1.5h", err: true},
+		{in: "d", err: true},
+		{in: "7s", err: true},
+		{in: "999999999999y", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, never, err := Parse(tt.in)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v; want error %t", err, tt.err)
+			}
+			if got != tt.want || never != tt.never {
+				t.Errorf("got %v, %t; want %v, %t", got, never, tt.want, tt.never)
+			}
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy := Policy{Min: time.Hour, Max: 30 * day}
+
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{"59m", false},
+		{"1h", true},
+		{"30d", true},
+		{"31d", false},
+		{"never", false},
+		{"soon", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, _, err := policy.Check(tt.in)
+			if (err == nil) != tt.ok {
+				t.Errorf("got error %v; want ok %t", err, tt.ok)
+			}
+		})
+	}
+
+	var values []string
+	for _, preset := range policy.Presets() {
+		values = append(values, preset.Value)
+	}
+	if got, want := len(values), 4; got != want {
+		t.Fatalf("got presets %v; want %d", values, want)
+	}
+	if got := policy.Default(); got != "30d" {
+		t.Errorf("got default %q; want %q", got, "30d")
+	}
+	if got := DefaultPolicy.Default(); got != "1y" {
+		t.Errorf("got default %q; want %q", got, "1y")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := map[time.Duration]string{
+		time.Minute:      "1 minute",
+		90 * time.Minute: "90 minutes",
+		2 * week:         "2 weeks",
+		year:             "1 year",
+		10 * day:         "10 days",
+	}
+
+	for d, want := range tests {
+		if got := Describe(d); got != want {
+			t.Errorf("Describe(%v) = %q; want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build ignore
+
+// gencss writes ui/static/css/chroma.css, the stylesheet for the markup rendered by the highlight package. It is run
+// by go generate in this directory, and must be run again whenever the style or the formatter options change.
+package main
+
+import (
+	"github.com/rlr524/snippetboxv2/internal/highlight"
+	"log"
+	"os"
+)
+
+func main() {
+	file, err := os.Create("../../ui/static/css/chroma.css")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = highlight.WriteCSS(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,183 @@
+// Package highlight renders snippet content as syntax highlighted HTML, using chroma. The markup uses CSS classes
+// rather than inline styles so it is allowed by the Content-Security-Policy set by the secureHeaders middleware; the
+// matching stylesheet is ui/static/css/chroma.css, which is generated by WriteCSS when go generate is run in this
+// directory.
+package highlight
+
+//go:generate go run gencss.go
+
+import (
+	"bytes"
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"html/template"
+	"io"
+	"sort"
+)
+
+// styleName is the chroma style used to generate ui/static/css/chroma.css.
+const styleName = "github"
+
+// Language is a language which can be chosen for a snippet. Name is shown to the user and Alias, which is also the
+// chroma lexer name, is stored with the snippet.
+type Language struct {
+	Alias string
+	Name  string
+}
+
+// languages is the list of languages offered on the snippet forms. Chroma supports many more, but a short list is
+// easier to choose from and auto-detection handles the rest.
+var languages = map[string]string{
+	"bash":       "Bash",
+	"c":          "C",
+	"cpp":        "C++",
+	"csharp":     "C#",
+	"css":        "CSS",
+	"diff":       "Diff",
+	"docker":     "Dockerfile",
+	"go":         "Go",
+	"html":       "HTML",
+	"java":       "Java",
+	"javascript": "JavaScript",
+	"json":       "JSON",
+	"kotlin":     "Kotlin",
+	"makefile":   "Makefile",
+	"markdown":   "Markdown",
+	"php":        "PHP",
+	"python":     "Python",
+	"ruby":       "Ruby",
+	"rust":       "Rust",
+	"sql":        "SQL",
+	"swift":      "Swift",
+	"text":       "Plain text",
+	"toml":       "TOML",
+	"typescript": "TypeScript",
+	"xml":        "XML",
+	"yaml":       "YAML",
+}
+
+// extensions maps each language to the file extension used when a snippet is downloaded.
+var extensions = map[string]string{
+	"bash":       ".sh",
+	"c":          ".c",
+	"cpp":        ".cpp",
+	"csharp":     ".cs",
+	"css":        ".css",
+	"diff":       ".diff",
+	"docker":     ".dockerfile",
+	"go":         ".go",
+	"html":       ".html",
+	"java":       ".java",
+	"javascript": ".js",
+	"json":       ".json",
+	"kotlin":     ".kt",
+	"makefile":   ".mk",
+	"markdown":   ".md",
+	"php":        ".php",
+	"python":     ".py",
+	"ruby":       ".rb",
+	"rust":       ".rs",
+	"sql":        ".sql",
+	"swift":      ".swift",
+	"text":       ".txt",
+	"toml":       ".toml",
+	"typescript": ".ts",
+	"xml":        ".xml",
+	"yaml":       ".yaml",
+}
+
+// formatter is safe for concurrent use, so a single instance is shared by every request. Line numbers are rendered
+// in a separate table column, so that copying the code doesn't copy them too, and each one is a link to an #L<n>
+// anchor.
+var formatter = html.New(
+	html.WithClasses(true),
+	html.WithLineNumbers(true),
+	html.LineNumbersInTable(true),
+	html.WithLinkableLineNumbers(true, "L"),
+)
+
+// Languages returns the languages which can be chosen for a snippet, sorted by name.
+func Languages() []Language {
+	list := make([]Language, 0, len(languages))
+	for alias, name := range languages {
+		list = append(list, Language{Alias: alias, Name: name})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Supported reports whether alias is one of the languages which can be chosen for a snippet.
+func Supported(alias string) bool {
+	_, ok := languages[alias]
+	return ok
+}
+
+// Name returns the display name of a language, or "Plain text" if it isn't supported.
+func Name(alias string) string {
+	if name, ok := languages[alias]; ok {
+		return name
+	}
+	return "Plain text"
+}
+
+// Extension returns the file extension for a language, or ".txt" if it isn't supported.
+func Extension(alias string) string {
+	if ext, ok := extensions[alias]; ok {
+		return ext
+	}
+	return ".txt"
+}
+
+// Detect guesses the language of some content, returning the alias of a supported language, or "text" if the
+// language can't be recognised.
+func Detect(content string) string {
+	lexer := lexers.Analyse(content)
+	if lexer == nil {
+		return "text"
+	}
+
+	// The lexer may have been found under a different name to the one used in the languages list, so check every
+	// name it's known by.
+	config := lexer.Config()
+	for _, alias := range append([]string{config.Name}, config.Aliases...) {
+		if Supported(alias) {
+			return alias
+		}
+	}
+	return "text"
+}
+
+// HTML returns content highlighted as the given language. Unsupported languages, including the empty string, are
+// rendered as plain text with the same markup, so line numbers and anchors work for every snippet.
+func HTML(content string, language string) (template.HTML, error) {
+	var lexer chroma.Lexer
+	if Supported(language) {
+		lexer = lexers.Get(language)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	// Coalesce merges runs of tokens of the same type, which makes the markup smaller.
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = formatter.Format(&buf, styles.Get(styleName), iterator)
+	if err != nil {
+		return "", err
+	}
+
+	// The formatter escapes the content itself, so its output is safe to include in a page as it is.
+	return template.HTML(buf.String()), nil
+}
+
+// WriteCSS writes the stylesheet for the highlighted markup.
+func WriteCSS(w io.Writer) error {
+	return formatter.WriteCSS(w, styles.Get(styleName))
+}
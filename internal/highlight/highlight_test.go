@@ -0,0 +1,118 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtension(t *testing.T) {
+	tests := []struct {
+		alias string
+		want  string
+	}{
+		{"go", ".go"},
+		{"cpp", ".cpp"},
+		{"docker", ".dockerfile"},
+		{"text", ".txt"},
+		{"cobol", ".txt"},
+		{"", ".txt"},
+		{"Go", ".txt"},
+	}
+
+	for _, tt := range tests {
+		if got := Extension(tt.alias); got != tt.want {
+			t.Errorf("Extension(%q): got %q; want %q", tt.alias, got, tt.want)
+		}
+	}
+
+	// Every language which can be chosen has its own extension.
+	for _, l := range Languages() {
+		if _, ok := extensions[l.Alias]; !ok {
+			t.Errorf("%s has no extension", l.Alias)
+		}
+	}
+}
+
+func TestUnsupportedLanguages(t *testing.T) {
+	tests := []struct {
+		name  string
+		alias string
+	}{
+		{"empty", ""},
+		{"unknown", "cobol"},
+		{"supported by chroma but not offered", "fortran"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if Supported(tt.alias) {
+				t.Errorf("got %q supported", tt.alias)
+			}
+			if got := Name(tt.alias); got != "Plain text" {
+				t.Errorf("got name %q; want Plain text", got)
+			}
+
+			// Unsupported languages are rendered as plain text, with the same line anchors.
+			html, err := HTML("one\ntwo", tt.alias)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{`id="L1"`, `href="#L2"`, "one", "two"} {
+				if !strings.Contains(string(html), want) {
+					t.Errorf("got %q; want it to contain %q", html, want)
+				}
+			}
+			if strings.Contains(string(html), `class="nx"`) {
+				t.Errorf("got %q; want no language tokens", html)
+			}
+		})
+	}
+}
+
+func TestHTMLEscaping(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+	}{
+		{"plain text", `<script>alert("x")</script> & more`, ""},
+		{"go", `s := "<script>alert(1)</script> &amp;"`, "go"},
+		{"html", `<script>alert(1)</script>`, "html"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := HTML(tt.content, tt.language)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(html), "<script") {
+				t.Errorf("got %q; want the markup escaped", html)
+			}
+			if !strings.Contains(string(html), "&lt;") {
+				t.Errorf("got %q; want &lt; in place of <", html)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"go", "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n", "go"},
+		{"bash", "#!/bin/bash\necho hi\n", "bash"},
+		{"empty", "", "text"},
+		{"prose", "An old silent pond", "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.content); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Package dbtest provides throwaway databases for tests which run against a real database rather than the mocks.
+// SQLite always runs, with a new database file for each test. MySQL and Postgres only run when the environment
+// variable holding a data source name is set, and are skipped otherwise. The database it names must be a dedicated,
+// disposable one, as every table is dropped and the migrations applied again for each test. The MySQL data source
+// name needs parseTime=true.
+package dbtest
+
+import (
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	_ "modernc.org/sqlite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Backend is a database the tests can run against.
+type Backend struct {
+	Dialect models.Dialect
+	// Env is the environment variable holding the data source name, or empty for SQLite.
+	Env string
+}
+
+// Backends lists every database the tests run against.
+var Backends = []Backend{
+	{models.SQLite, ""},
+	{models.MySQL, "SNIPPETBOX_TEST_MYSQL_DSN"},
+	{models.Postgres, "SNIPPETBOX_TEST_POSTGRES_DSN"},
+}
+
+// tables lists every table created by the migrations, along with schema_migrations, so that they can be dropped
+// between tests. It must be kept up to date as migrations add tables.
+var tables = []string{"snippet_tags", "tags", "snippet_revisions", "snippets_archive", "snippets", "tokens",
+	"sessions", "users", "schema_migrations"}
+
+// ForEach runs fn as a subtest, named after the dialect, against a new, empty database for each backend.
+func ForEach(t *testing.T, fn func(t *testing.T, db *models.DB)) {
+	t.Helper()
+
+	for _, b := range Backends {
+		t.Run(string(b.Dialect), func(t *testing.T) {
+			fn(t, New(t, b))
+		})
+	}
+}
+
+// New returns a connection to an empty database with every migration applied, which is closed when the test
+// finishes. The test is skipped if the backend hasn't been configured.
+func New(t *testing.T, b Backend) *models.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	if b.Dialect != models.SQLite {
+		dsn = os.Getenv(b.Env)
+		if dsn == "" {
+			t.Skipf("%s isn't set", b.Env)
+		}
+	}
+
+	db, err := models.Open(b.Dialect, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	for _, table := range tables {
+		_, err = db.Exec(`DROP TABLE IF EXISTS ` + table)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = db.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
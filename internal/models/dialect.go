@@ -0,0 +1,263 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect is the SQL dialect of the database behind the models. Its value is the name used for the database by the
+// -db-driver flag.
+type Dialect string
+
+const (
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// driverName returns the name of the database/sql driver used for d.
+func (d Dialect) driverName() string {
+	if d == Postgres {
+		return "pgx"
+	}
+	return string(d)
+}
+
+// Supported reports whether d is one of the dialects the models can use.
+func (d Dialect) Supported() bool {
+	return d == MySQL || d == SQLite || d == Postgres
+}
+
+// DefaultDSN returns the data source name used when none is given: the snippetbox database on the lancer server,
+// logged in to as web with the given password, or snippetbox.db in the working directory for SQLite.
+func (d Dialect) DefaultDSN(password string) string {
+	switch d {
+	case Postgres:
+		return fmt.Sprintf("postgres://web:%s@lancer:5432/snippetbox", url.QueryEscape(password))
+	case SQLite:
+		return "snippetbox.db"
+	default:
+		return fmt.Sprintf("web:%s@tcp(lancer:3306)/snippetbox?parseTime=true", password)
+	}
+}
+
+// sqliteReplacer translates the MySQL-specific parts of the models' SQL into SQLite. SQLite has no date type, so
+// times are stored as text in the same "YYYY-MM-DD HH:MM:SS" UTC format as datetime('now') returns, which keeps
+// comparisons between them correct.
+var sqliteReplacer = strings.NewReplacer(
+	"DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY)", "datetime('now', ? || ' days')",
+	"UTC_TIMESTAMP()", "datetime('now')",
+)
+
+// postgresReplacer translates the MySQL-specific parts of the models' SQL into Postgres. Times are stored in
+// TIMESTAMP columns holding UTC, as they are in MySQL's DATETIME columns.
+var postgresReplacer = strings.NewReplacer(
+	"DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY)", "(NOW() AT TIME ZONE 'UTC') + make_interval(days => ?)",
+	"UTC_TIMESTAMP()", "(NOW() AT TIME ZONE 'UTC')",
+)
+
+// sqliteTimeFormat is the format of times stored by SQLite, matching datetime('now').
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// translate rewrites a statement written in MySQL's dialect into d.
+func (d Dialect) translate(query string) string {
+	switch d {
+	case SQLite:
+		return sqliteReplacer.Replace(query)
+	case Postgres:
+		return rebind(postgresReplacer.Replace(query))
+	default:
+		return query
+	}
+}
+
+// rebind replaces the ? placeholders in a statement with Postgres' numbered $1, $2, ... placeholders. Question marks
+// inside string literals are left alone.
+func rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	quoted := false
+
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// args converts the arguments to a statement into the types d expects. Times are stored in UTC to the second on
+// every database; MySQL would otherwise round fractional seconds, and Postgres would keep them.
+func (d Dialect) args(args []any) []any {
+	converted := make([]any, len(args))
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			t = t.UTC().Truncate(time.Second)
+			if d == SQLite {
+				arg = t.Format(sqliteTimeFormat)
+			} else {
+				arg = t
+			}
+		}
+		converted[i] = arg
+	}
+	return converted
+}
+
+// DB is a database connection which runs the models' SQL, written for MySQL, in the dialect of the database it is
+// connected to. Everything except Exec, Query, QueryRow and Begin is passed straight through to the *sql.DB.
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+// sqlitePragmas are added to SQLite data source names. Foreign keys are off by default in SQLite, the busy timeout
+// and immediate transactions make concurrent writers wait for each other rather than fail, and WAL mode lets
+// readers carry on while a write is in progress. The time format is one the session store's julianday() can read.
+const sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)" +
+	"&_txlock=immediate&_time_format=sqlite"
+
+// Open opens a connection pool to the database with the given data source name, using the driver for dialect, and
+// checks that the database can be reached. The driver must have been registered by the caller.
+func Open(dialect Dialect, dsn string) (*DB, error) {
+	if dialect == SQLite {
+		if strings.Contains(dsn, "?") {
+			dsn += "&" + sqlitePragmas
+		} else {
+			dsn += "?" + sqlitePragmas
+		}
+	}
+
+	db, err := sql.Open(dialect.driverName(), dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &DB{DB: db, Dialect: dialect}, nil
+}
+
+func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.DB.Exec(db.Dialect.translate(query), db.Dialect.args(args)...)
+}
+
+// insert runs an INSERT statement and returns the ID of the new row.
+func (db *DB) insert(query string, args ...any) (int, error) {
+	return insert(db, db.Dialect, query, args...)
+}
+
+// execer is the part of DB and Tx used by insert.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// insert runs an INSERT statement with e and returns the ID of the new row. The Postgres driver doesn't support
+// LastInsertId, so there the ID is asked for with a RETURNING clause instead.
+func insert(e execer, dialect Dialect, query string, args ...any) (int, error) {
+	if dialect == Postgres {
+		var id int
+		err := e.QueryRow(query+` RETURNING id`, args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := e.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func (db *DB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.DB.Query(db.Dialect.translate(query), db.Dialect.args(args)...)
+}
+
+func (db *DB) QueryRow(query string, args ...any) *sql.Row {
+	return db.DB.QueryRow(db.Dialect.translate(query), db.Dialect.args(args)...)
+}
+
+// Begin starts a transaction which translates its statements in the same way as db.
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, dialect: db.Dialect}, nil
+}
+
+// Tx is a transaction started by DB.Begin.
+type Tx struct {
+	*sql.Tx
+	dialect Dialect
+}
+
+func (tx *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.translate(query), tx.dialect.args(args)...)
+}
+
+func (tx *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.dialect.translate(query), tx.dialect.args(args)...)
+}
+
+func (tx *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRow(tx.dialect.translate(query), tx.dialect.args(args)...)
+}
+
+// insert runs an INSERT statement in the transaction and returns the ID of the new row.
+func (tx *Tx) insert(query string, args ...any) (int, error) {
+	return insert(tx, tx.dialect, query, args...)
+}
+
+// isDuplicate reports whether err is a unique constraint violation which mentions one of the given names. Each
+// driver reports violations differently: MySQL with error 1062 naming the key, Postgres with SQLSTATE 23505 naming
+// the constraint, and SQLite with an extended result code and a message naming the table and column. The Postgres
+// and SQLite errors are recognised by their methods, so that this package doesn't depend on their drivers.
+func isDuplicate(err error, names ...string) bool {
+	var mySQLError *mysql.MySQLError
+	var stated interface{ SQLState() string }
+	var coded interface{ Code() int }
+
+	switch {
+	case errors.As(err, &mySQLError):
+		if mySQLError.Number != 1062 {
+			return false
+		}
+	case errors.As(err, &stated):
+		if stated.SQLState() != "23505" {
+			return false
+		}
+	case errors.As(err, &coded):
+		// SQLITE_CONSTRAINT_UNIQUE and SQLITE_CONSTRAINT_PRIMARYKEY.
+		if coded.Code() != 2067 && coded.Code() != 1555 {
+			return false
+		}
+	default:
+		return false
+	}
+
+	for _, name := range names {
+		if strings.Contains(err.Error(), name) {
+			return true
+		}
+	}
+	return false
+}
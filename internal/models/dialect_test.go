@@ -0,0 +1,42 @@
+package models
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"none", `SELECT 1`, `SELECT 1`},
+		{"several", `SELECT id FROM snippets WHERE id = ? AND user_id = ?`,
+			`SELECT id FROM snippets WHERE id = $1 AND user_id = $2`},
+		{"quoted", `SELECT '?' || title FROM snippets WHERE id = ?`, `SELECT '?' || title FROM snippets WHERE id = $1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rebind(tt.query); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	query := `UPDATE tokens SET expires = DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY) WHERE id = ?`
+	want := map[Dialect]string{
+		MySQL:  query,
+		SQLite: `UPDATE tokens SET expires = datetime('now', ? || ' days') WHERE id = ?`,
+		Postgres: `UPDATE tokens SET expires = (NOW() AT TIME ZONE 'UTC') + make_interval(days => $1) ` +
+			`WHERE id = $2`,
+	}
+
+	for dialect, w := range want {
+		t.Run(string(dialect), func(t *testing.T) {
+			if got := dialect.translate(query); got != w {
+				t.Errorf("got %q; want %q", got, w)
+			}
+		})
+	}
+}
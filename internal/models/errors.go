@@ -9,6 +9,15 @@ var (
 	// ErrInvalidCredentials is used if a user tries to log in with an invalid email address or password.
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 
+	// ErrInactiveUser is used if a user gives the right credentials for an account which has been deactivated.
+	ErrInactiveUser = errors.New("models: user is inactive")
+
 	// ErrDuplicateEmail is used if a user tries to sign up with an email address that's already in use.
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	// ErrInvalidToken is used if an API token doesn't exist, has been revoked, or has expired.
+	ErrInvalidToken = errors.New("models: invalid token")
+
+	// ErrAlreadyRead is used if a burn after reading snippet has already been read.
+	ErrAlreadyRead = errors.New("models: snippet has already been read")
 )
@@ -0,0 +1,349 @@
+package models
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the migrations of every dialect, in a directory named after the dialect. Each migration is a
+// pair of files, NNNN_name.up.sql and NNNN_name.down.sql, where NNNN is its version. Every dialect has the same
+// versions, so that a version means the same schema whichever database is used.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// ErrSchemaMismatch is used if the database's schema isn't the version the application was built for.
+var ErrSchemaMismatch = errors.New("models: database schema doesn't match the application")
+
+// schemaMigrationsTable creates the table which records the migrations applied to a database, in each dialect.
+var schemaMigrationsTable = map[Dialect]string{
+	MySQL: `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER NOT NULL PRIMARY KEY, applied DATETIME NOT NULL)`,
+	Postgres: `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER NOT NULL PRIMARY KEY, applied TIMESTAMP NOT NULL)`,
+	SQLite: `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER NOT NULL PRIMARY KEY, applied DATETIME NOT NULL)`,
+}
+
+// schemaMigrationsExists counts the schema_migrations tables in the database, in each dialect, so that the migrations
+// can be read without creating the table.
+var schemaMigrationsExists = map[Dialect]string{
+	MySQL: `SELECT COUNT(*) FROM information_schema.tables
+	WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'`,
+	Postgres: `SELECT COUNT(*) FROM information_schema.tables
+	WHERE table_schema = current_schema() AND table_name = 'schema_migrations'`,
+	SQLite: `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`,
+}
+
+// Migration is a versioned change to the schema, with the SQL to make it and to undo it.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus is a migration known to the application or recorded in the database, and when it was applied.
+type MigrationStatus struct {
+	Version int
+	// Name is empty if the migration was applied by a newer version of the application.
+	Name string
+	// Applied is the zero time if the migration hasn't been applied.
+	Applied time.Time
+}
+
+// Pending reports whether the migration is yet to be applied.
+func (s MigrationStatus) Pending() bool {
+	return s.Applied.IsZero()
+}
+
+// Unknown reports whether the migration was applied by a newer version of the application.
+func (s MigrationStatus) Unknown() bool {
+	return s.Name == ""
+}
+
+// Migrations returns the migrations for db's dialect, in version order.
+func (db *DB) Migrations() ([]Migration, error) {
+	return loadMigrations(db.Dialect)
+}
+
+// loadMigrations reads the embedded migrations for dialect, in version order. Every migration must have both an up
+// and a down file.
+func loadMigrations(dialect Dialect) ([]Migration, error) {
+	dir := path.Join("migrations", string(dialect))
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		versionText, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionText)
+		if !ok || !found || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("models: invalid migration file name %q", entry.Name())
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("models: migration %d has two names, %q and %q", version, m.Name, name)
+		}
+
+		b, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if direction == "up" {
+			m.up = string(b)
+		} else {
+			m.down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("models: migration %d is missing its up or down file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// applied returns the time each migration recorded in the database was applied, by version. A database which has
+// never been migrated has no schema_migrations table, and so no migrations applied.
+func (db *DB) applied() (map[int]time.Time, error) {
+	var tables int
+	err := db.QueryRow(schemaMigrationsExists[db.Dialect]).Scan(&tables)
+	if err != nil {
+		return nil, err
+	}
+	if tables == 0 {
+		return map[int]time.Time{}, nil
+	}
+
+	rows, err := db.Query(`SELECT version, applied FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var t time.Time
+		err = rows.Scan(&version, &t)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = t
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// MigrationStatus returns every migration known to the application, along with any applied by a newer version of
+// it, in version order.
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := db.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := db.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		statuses = append(statuses, MigrationStatus{Version: m.Version, Name: m.Name, Applied: applied[m.Version]})
+		delete(applied, m.Version)
+	}
+	for version, t := range applied {
+		statuses = append(statuses, MigrationStatus{Version: version, Applied: t})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// CheckSchema returns an error wrapping ErrSchemaMismatch unless every migration known to the application has been
+// applied, and no others have.
+func (db *DB) CheckSchema() error {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statuses {
+		switch {
+		case s.Unknown():
+			return fmt.Errorf("%w: migration %d was applied by a newer version", ErrSchemaMismatch, s.Version)
+		case s.Pending():
+			return fmt.Errorf("%w: migration %d (%s) hasn't been applied", ErrSchemaMismatch, s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+// MigrateUp applies every pending migration in version order, and returns the ones it applied. Each migration is
+// applied in a transaction along with its schema_migrations record. MySQL commits schema changes straight away, so
+// there a migration which fails part way through has to be tidied up by hand.
+func (db *DB) MigrateUp() ([]Migration, error) {
+	_, err := db.DB.Exec(schemaMigrationsTable[db.Dialect])
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := db.applied()
+	if err != nil {
+		return nil, err
+	}
+	if err = checkUnknown(migrations, applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		err = db.runMigration(m.up, `INSERT INTO schema_migrations (version, applied) VALUES (?, UTC_TIMESTAMP())`,
+			m.Version)
+		if err != nil {
+			return done, fmt.Errorf("models: migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// checkUnknown returns an error wrapping ErrSchemaMismatch if a migration was applied by a newer version of the
+// application, as its schema can't be changed safely by this one.
+func checkUnknown(migrations []Migration, applied map[int]time.Time) error {
+	known := map[int]bool{}
+	for _, m := range migrations {
+		known[m.Version] = true
+	}
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("%w: migration %d was applied by a newer version", ErrSchemaMismatch, version)
+		}
+	}
+	return nil
+}
+
+// MigrateDown reverts the most recently applied migration and returns it, or returns nil if no migrations have been
+// applied.
+func (db *DB) MigrateDown() (*Migration, error) {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *MigrationStatus
+	for i := range statuses {
+		if !statuses[i].Pending() {
+			latest = &statuses[i]
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	if latest.Unknown() {
+		return nil, fmt.Errorf("%w: migration %d was applied by a newer version", ErrSchemaMismatch, latest.Version)
+	}
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range migrations {
+		if m.Version != latest.Version {
+			continue
+		}
+
+		err = db.runMigration(m.down, `DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+		if err != nil {
+			return nil, fmt.Errorf("models: migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		return &m, nil
+	}
+	return nil, nil
+}
+
+// runMigration runs the statements in script, followed by record, which updates schema_migrations, in a transaction.
+func (db *DB) runMigration(script, record string, version int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func(tx *Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	for _, stmt := range statements(script) {
+		// The statements are written for their own dialect, so they aren't translated.
+		_, err = tx.Tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(record, version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// statements splits a script into the statements in it, as the MySQL driver only runs one statement per Exec unless
+// multiStatements is set in the data source name. The scripts have no semicolons inside string literals.
+func statements(script string) []string {
+	var stmts []string
+	for _, stmt := range strings.Split(script, ";") {
+		if strings.TrimSpace(stripComments(stmt)) != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// stripComments removes -- comments from SQL, so that statements can tell when a part of a script has no statement
+// in it. The scripts have no -- inside string literals.
+func stripComments(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if before, _, found := strings.Cut(line, "--"); found {
+			lines[i] = before
+		}
+	}
+	return strings.Join(lines, "\n")
+}
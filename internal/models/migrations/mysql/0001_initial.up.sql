@@ -0,0 +1,31 @@
+-- The schema of a MySQL database as it was set up by hand before migrations were added. Times are stored in UTC.
+-- The database should use the utf8mb4 character set.
+--
+-- Tables are only created if they don't exist, so that those hand-made databases can be brought under migrations
+-- with -migrate up, keeping their data. Everything added since is in the later migrations.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    active TINYINT NOT NULL DEFAULT 1,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    INDEX idx_snippets_created (created)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    token CHAR(43) PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry TIMESTAMP(6) NOT NULL,
+    INDEX sessions_expiry_idx (expiry)
+);
@@ -0,0 +1,19 @@
+-- Removes the tables and snippet columns added by the up migration, and so the data in them.
+
+DROP TABLE IF EXISTS tokens;
+DROP TABLE IF EXISTS snippet_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS snippet_revisions;
+DROP TABLE IF EXISTS snippets_archive;
+
+ALTER TABLE snippets
+    DROP INDEX idx_snippets_fulltext,
+    DROP INDEX snippets_uc_slug,
+    DROP INDEX idx_snippets_user_id,
+    DROP INDEX idx_snippets_expires,
+    DROP COLUMN user_id,
+    DROP COLUMN read_at,
+    DROP COLUMN burn_after_reading,
+    DROP COLUMN slug,
+    DROP COLUMN visibility,
+    DROP COLUMN language;
@@ -0,0 +1,72 @@
+-- Adds the snippet columns and the tables for revisions, tags, the archive and API tokens to the initial schema.
+
+ALTER TABLE snippets
+    ADD COLUMN language VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    ADD COLUMN slug VARCHAR(32) NULL,
+    ADD COLUMN burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN read_at DATETIME NULL,
+    ADD COLUMN user_id INTEGER NULL,
+    ADD INDEX idx_snippets_expires (expires),
+    ADD INDEX idx_snippets_user_id (user_id);
+
+-- Existing snippets get a random slug, so that they can be made unlisted later.
+UPDATE snippets SET slug = LOWER(HEX(RANDOM_BYTES(16)));
+
+ALTER TABLE snippets
+    MODIFY COLUMN slug VARCHAR(32) NOT NULL,
+    ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
+
+CREATE FULLTEXT INDEX idx_snippets_fulltext ON snippets (title, content);
+
+CREATE TABLE snippets_archive (
+    id INTEGER NOT NULL PRIMARY KEY,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    language VARCHAR(50) NOT NULL DEFAULT '',
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    slug VARCHAR(32) NOT NULL,
+    burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    read_at DATETIME NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    user_id INTEGER NULL,
+    archived DATETIME NOT NULL
+);
+
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    user_id INTEGER NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_revisions_uc_revision UNIQUE (snippet_id, revision)
+);
+
+CREATE TABLE tags (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(30) NOT NULL,
+    CONSTRAINT tags_uc_name UNIQUE (name)
+);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, tag_id),
+    INDEX idx_snippet_tags_tag_id (tag_id)
+);
+
+CREATE TABLE tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    scope VARCHAR(20) NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NULL,
+    last_used DATETIME NULL,
+    CONSTRAINT tokens_uc_hash UNIQUE (hash),
+    INDEX idx_tokens_user_id (user_id)
+);
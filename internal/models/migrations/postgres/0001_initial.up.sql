@@ -0,0 +1,34 @@
+-- The initial schema of a Postgres database, matching the MySQL schema which was set up by hand before migrations
+-- were added. Times are stored in UTC in TIMESTAMP columns, apart from the session expiry which the session store
+-- keeps in a TIMESTAMPTZ column.
+--
+-- As in MySQL, tables are only created if they don't exist, so that a database set up by hand can be brought under
+-- migrations with -migrate up. Everything added since is in the later migrations.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created TIMESTAMP NOT NULL,
+    active SMALLINT NOT NULL DEFAULT 1,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS snippets (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expires TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_snippets_created ON snippets (created);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    token TEXT PRIMARY KEY,
+    data BYTEA NOT NULL,
+    expiry TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sessions_expiry_idx ON sessions (expiry);
@@ -0,0 +1,18 @@
+-- Removes the tables and snippet columns added by the up migration, and so the data in them. Dropping the columns
+-- also drops their indexes and constraints.
+
+DROP TABLE IF EXISTS tokens;
+DROP TABLE IF EXISTS snippet_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS snippet_revisions;
+DROP TABLE IF EXISTS snippets_archive;
+
+DROP INDEX IF EXISTS idx_snippets_expires;
+
+ALTER TABLE snippets
+    DROP COLUMN user_id,
+    DROP COLUMN read_at,
+    DROP COLUMN burn_after_reading,
+    DROP COLUMN slug,
+    DROP COLUMN visibility,
+    DROP COLUMN language;
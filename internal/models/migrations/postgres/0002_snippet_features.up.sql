@@ -0,0 +1,73 @@
+-- Adds the snippet columns and the tables for revisions, tags, the archive and API tokens to the initial schema.
+
+ALTER TABLE snippets
+    ADD COLUMN language VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    ADD COLUMN slug VARCHAR(32) NULL,
+    ADD COLUMN burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN read_at TIMESTAMP NULL,
+    ADD COLUMN user_id INTEGER NULL;
+
+-- Existing snippets get a random slug, so that they can be made unlisted later.
+UPDATE snippets SET slug = replace(gen_random_uuid()::text, '-', '');
+
+ALTER TABLE snippets
+    ALTER COLUMN slug SET NOT NULL,
+    ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
+
+CREATE INDEX idx_snippets_expires ON snippets (expires);
+CREATE INDEX idx_snippets_user_id ON snippets (user_id);
+
+CREATE TABLE snippets_archive (
+    id INTEGER PRIMARY KEY,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    language VARCHAR(50) NOT NULL DEFAULT '',
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    slug VARCHAR(32) NOT NULL,
+    burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    read_at TIMESTAMP NULL,
+    created TIMESTAMP NOT NULL,
+    expires TIMESTAMP NOT NULL,
+    user_id INTEGER NULL,
+    archived TIMESTAMP NOT NULL
+);
+
+CREATE TABLE snippet_revisions (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    snippet_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    user_id INTEGER NULL,
+    created TIMESTAMP NOT NULL,
+    CONSTRAINT snippet_revisions_uc_revision UNIQUE (snippet_id, revision)
+);
+
+CREATE TABLE tags (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name VARCHAR(30) NOT NULL,
+    CONSTRAINT tags_uc_name UNIQUE (name)
+);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, tag_id)
+);
+
+CREATE INDEX idx_snippet_tags_tag_id ON snippet_tags (tag_id);
+
+CREATE TABLE tokens (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    scope VARCHAR(20) NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expires TIMESTAMP NULL,
+    last_used TIMESTAMP NULL,
+    CONSTRAINT tokens_uc_hash UNIQUE (hash)
+);
+
+CREATE INDEX idx_tokens_user_id ON tokens (user_id);
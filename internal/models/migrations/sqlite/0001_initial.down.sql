@@ -0,0 +1,5 @@
+-- Removes every table, and so all of the application's data.
+
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS snippets;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,34 @@
+-- The initial schema of a SQLite database, matching the MySQL schema which was set up by hand before migrations were
+-- added. Times are stored as text in UTC, in the format returned by datetime('now'), apart from the session expiry
+-- which the session store keeps as a Julian day number.
+--
+-- As in MySQL, tables are only created if they don't exist, so that a database set up by hand can be brought under
+-- migrations. Everything added since is in the later migrations.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL,
+    hashed_password TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    active INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS snippets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_snippets_created ON snippets (created);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    token TEXT PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry REAL NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sessions_expiry_idx ON sessions (expiry);
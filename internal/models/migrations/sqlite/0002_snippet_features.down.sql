@@ -0,0 +1,19 @@
+-- Removes the tables and snippet columns added by the up migration, and so the data in them. SQLite can't drop an
+-- indexed column, so the indexes go first.
+
+DROP TABLE IF EXISTS tokens;
+DROP TABLE IF EXISTS snippet_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS snippet_revisions;
+DROP TABLE IF EXISTS snippets_archive;
+
+DROP INDEX IF EXISTS idx_snippets_user_id;
+DROP INDEX IF EXISTS idx_snippets_expires;
+DROP INDEX IF EXISTS snippets_uc_slug;
+
+ALTER TABLE snippets DROP COLUMN user_id;
+ALTER TABLE snippets DROP COLUMN read_at;
+ALTER TABLE snippets DROP COLUMN burn_after_reading;
+ALTER TABLE snippets DROP COLUMN slug;
+ALTER TABLE snippets DROP COLUMN visibility;
+ALTER TABLE snippets DROP COLUMN language;
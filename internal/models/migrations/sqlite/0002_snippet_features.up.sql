@@ -0,0 +1,66 @@
+-- Adds the snippet columns and the tables for revisions, tags, the archive and API tokens to the initial schema.
+-- SQLite adds one column per ALTER TABLE, and can only add a NOT NULL column with a default.
+
+ALTER TABLE snippets ADD COLUMN language TEXT NOT NULL DEFAULT '';
+ALTER TABLE snippets ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE snippets ADD COLUMN slug TEXT NOT NULL DEFAULT '';
+ALTER TABLE snippets ADD COLUMN burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE snippets ADD COLUMN read_at DATETIME;
+ALTER TABLE snippets ADD COLUMN user_id INTEGER;
+
+-- Existing snippets get a random slug, so that they can be made unlisted later.
+UPDATE snippets SET slug = lower(hex(randomblob(16)));
+
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets (slug);
+CREATE INDEX idx_snippets_expires ON snippets (expires);
+CREATE INDEX idx_snippets_user_id ON snippets (user_id);
+
+CREATE TABLE snippets_archive (
+    id INTEGER PRIMARY KEY,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    language TEXT NOT NULL DEFAULT '',
+    visibility TEXT NOT NULL DEFAULT 'public',
+    slug TEXT NOT NULL,
+    burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    read_at DATETIME,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    user_id INTEGER,
+    archived DATETIME NOT NULL
+);
+
+CREATE TABLE snippet_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    user_id INTEGER,
+    created DATETIME NOT NULL,
+    UNIQUE (snippet_id, revision)
+);
+
+CREATE TABLE tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, tag_id)
+);
+
+CREATE INDEX idx_snippet_tags_tag_id ON snippet_tags (tag_id);
+
+CREATE TABLE tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    scope TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME,
+    last_used DATETIME
+);
@@ -0,0 +1,168 @@
+package models_test
+
+import (
+	"errors"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"github.com/rlr524/snippetboxv2/internal/models/dbtest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMigrationFiles(t *testing.T) {
+	var want []int
+	for _, dialect := range []models.Dialect{models.MySQL, models.Postgres, models.SQLite} {
+		// Reading the migrations doesn't need a connection to the database.
+		migrations, err := (&models.DB{Dialect: dialect}).Migrations()
+		if err != nil {
+			t.Fatalf("%s: %v", dialect, err)
+		}
+
+		var versions []int
+		for _, m := range migrations {
+			versions = append(versions, m.Version)
+		}
+		if want == nil {
+			want = versions
+		} else if !slices.Equal(versions, want) {
+			t.Errorf("%s: got versions %v; want %v", dialect, versions, want)
+		}
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		// newTestDB has applied every migration.
+		err := db.CheckSchema()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		done, err := db.MigrateUp()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(done) != 0 {
+			t.Errorf("got %d migrations applied twice; want 0", len(done))
+		}
+
+		migrations, err := db.Migrations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		latest := migrations[len(migrations)-1]
+
+		reverted, err := db.MigrateDown()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reverted == nil || reverted.Version != latest.Version {
+			t.Fatalf("got %v reverted; want migration %d", reverted, latest.Version)
+		}
+
+		err = db.CheckSchema()
+		if !errors.Is(err, models.ErrSchemaMismatch) {
+			t.Errorf("got %v after reverting; want ErrSchemaMismatch", err)
+		}
+
+		statuses, err := db.MigrationStatus()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := statuses[len(statuses)-1]; !s.Pending() {
+			t.Errorf("got migration %d applied at %v; want it pending", s.Version, s.Applied)
+		}
+
+		done, err = db.MigrateUp()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(done) != 1 || done[0].Version != latest.Version {
+			t.Errorf("got %v applied; want migration %d", done, latest.Version)
+		}
+
+		// A migration applied by a newer version of the application stops this one from running.
+		_, err = db.Exec(`INSERT INTO schema_migrations (version, applied) VALUES (?, UTC_TIMESTAMP())`,
+			latest.Version+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.CheckSchema()
+		if !errors.Is(err, models.ErrSchemaMismatch) {
+			t.Errorf("got %v with a newer migration; want ErrSchemaMismatch", err)
+		}
+		_, err = db.MigrateUp()
+		if !errors.Is(err, models.ErrSchemaMismatch) {
+			t.Errorf("got %v migrating up with a newer migration; want ErrSchemaMismatch", err)
+		}
+	})
+}
+
+func TestMigrateHandMadeDatabase(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		// Reverting every migration but the first leaves the schema which was set up by hand, and dropping
+		// schema_migrations makes it look as it did before migrations were added.
+		migrations, err := db.Migrations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range migrations[1:] {
+			_, err = db.MigrateDown()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err = db.Exec(`DROP TABLE schema_migrations`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []int
+		for _, title := range []string{"Haiku", "Limerick"} {
+			_, err = db.Exec(`INSERT INTO snippets (title, content, created, expires)
+                              VALUES (?, 'An old silent pond', UTC_TIMESTAMP(), ?)`, title, time.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		rows, err := db.Query(`SELECT id FROM snippets ORDER BY id`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var id int
+			if err = rows.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		_ = rows.Close()
+
+		done, err := db.MigrateUp()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(done) != len(migrations) {
+			t.Errorf("got %d migrations applied; want %d", len(done), len(migrations))
+		}
+		err = db.CheckSchema()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The existing snippets are kept, each with its own slug.
+		m := &models.SnippetModel{DB: db}
+		slugs := map[string]bool{}
+		for _, id := range ids {
+			s, err := m.Get(id)
+			if err != nil {
+				t.Fatalf("snippet %d: %v", id, err)
+			}
+			if s.Slug == "" || slugs[s.Slug] || s.Visibility != models.VisibilityPublic {
+				t.Errorf("snippet %d: got slug %q and visibility %q; want a unique slug and public", id, s.Slug,
+					s.Visibility)
+			}
+			slugs[s.Slug] = true
+		}
+	})
+}
@@ -0,0 +1,82 @@
+// Package mocks provides in-memory implementations of the repository interfaces in the models package, so that the
+// handlers can be tested without a database. They follow the same rules as the database models, such as hiding
+// expired snippets and only listing public ones, but make no attempt to be fast.
+package mocks
+
+import (
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"sort"
+	"time"
+)
+
+// Store holds one of each mock model, wired together so that they share data in the same way as the tables in the
+// database do; for example deleting a snippet also removes its tags and revisions.
+type Store struct {
+	Snippets  *SnippetModel
+	Users     *UserModel
+	Revisions *SnippetRevisionModel
+	Tokens    *TokenModel
+	Tags      *TagModel
+	Sessions  *SessionModel
+}
+
+// NewStore returns a Store with every model empty.
+func NewStore() *Store {
+	s := &Store{
+		Snippets:  &SnippetModel{snippets: make(map[int]*models.Snippet)},
+		Users:     &UserModel{users: make(map[int]*models.User)},
+		Revisions: &SnippetRevisionModel{revisions: make(map[int][]*models.SnippetRevision)},
+		Tokens:    &TokenModel{tokens: make(map[string]*models.Token)},
+		Tags:      &TagModel{tags: make(map[int][]string), ids: make(map[string]int)},
+		Sessions:  &SessionModel{},
+	}
+	s.Snippets.users = s.Users
+	s.Snippets.tags = s.Tags
+	s.Snippets.revisions = s.Revisions
+	s.Revisions.users = s.Users
+	s.Tags.snippets = s.Snippets
+	return s
+}
+
+// Check at compile time that the mocks implement the interfaces.
+var (
+	_ models.SnippetRepository  = (*SnippetModel)(nil)
+	_ models.UserRepository     = (*UserModel)(nil)
+	_ models.RevisionRepository = (*SnippetRevisionModel)(nil)
+	_ models.TokenRepository    = (*TokenModel)(nil)
+	_ models.TagRepository      = (*TagModel)(nil)
+	_ models.SessionRepository  = (*SessionModel)(nil)
+)
+
+// paginate returns one page of records, and the pagination metadata for it, in the same way as the database models.
+func paginate[T any](records []T, page, pageSize int) ([]T, models.Metadata) {
+	pageSize = models.ClampPageSize(pageSize)
+	page = max(page, 1)
+
+	start := min((page-1)*pageSize, len(records))
+	end := min(start+pageSize, len(records))
+	return records[start:end], models.CalculateMetadata(len(records), page, pageSize)
+}
+
+// SessionModel is an in-memory models.SessionRepository. There are no sessions to purge, as the tests use the
+// session manager's own in-memory store.
+type SessionModel struct{}
+
+func (m *SessionModel) DeleteExpired() (int, error) {
+	return 0, nil
+}
+
+// sortNewestFirst orders snippets by descending ID, which is the order of every listing.
+func sortNewestFirst(snippets []*models.Snippet) {
+	sort.Slice(snippets, func(i, j int) bool { return snippets[i].ID > snippets[j].ID })
+}
+
+// live reports whether a snippet hasn't expired yet.
+func live(s *models.Snippet) bool {
+	return s.Expires.After(time.Now())
+}
+
+// listed reports whether a snippet appears in the public listings, matching listedOnly in the models package.
+func listed(s *models.Snippet) bool {
+	return live(s) && s.Visibility == models.VisibilityPublic && !s.BurnAfterReading
+}
@@ -0,0 +1,84 @@
+package mocks
+
+import (
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"sync"
+	"time"
+)
+
+// SnippetRevisionModel is an in-memory models.RevisionRepository. Create it with NewStore.
+type SnippetRevisionModel struct {
+	mu        sync.Mutex
+	revisions map[int][]*models.SnippetRevision
+	nextID    int
+
+	users *UserModel
+}
+
+// copyOf returns a copy of a stored revision with the author's name filled in.
+func (m *SnippetRevisionModel) copyOf(r *models.SnippetRevision) *models.SnippetRevision {
+	c := *r
+	if u, err := m.users.Get(r.CreatedBy.ID); err == nil {
+		c.CreatedBy.Name = u.Name
+	}
+	return &c
+}
+
+// insert records a new revision of the given snippet. It is called by SnippetModel, as the database models record
+// revisions along with the change to the snippet.
+func (m *SnippetRevisionModel) insert(snippetID int, title string, content string, userID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	revision := len(m.revisions[snippetID]) + 1
+	m.revisions[snippetID] = append(m.revisions[snippetID], &models.SnippetRevision{
+		ID:        m.nextID,
+		SnippetID: snippetID,
+		Revision:  revision,
+		Title:     title,
+		Content:   content,
+		Created:   time.Now().UTC().Truncate(time.Second),
+		CreatedBy: models.User{ID: userID},
+	})
+	return revision, nil
+}
+
+// stored returns copies of the stored revisions of the given snippet, oldest first.
+func (m *SnippetRevisionModel) stored(snippetID int) []models.SnippetRevision {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revisions := make([]models.SnippetRevision, len(m.revisions[snippetID]))
+	for i, r := range m.revisions[snippetID] {
+		revisions[i] = *r
+	}
+	return revisions
+}
+
+func (m *SnippetRevisionModel) Get(snippetID int, revision int) (*models.SnippetRevision, error) {
+	for _, r := range m.stored(snippetID) {
+		if r.Revision == revision {
+			return m.copyOf(&r), nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetRevisionModel) GetAll(snippetID int) ([]*models.SnippetRevision, error) {
+	stored := m.stored(snippetID)
+
+	var revisions []*models.SnippetRevision
+	for i := len(stored) - 1; i >= 0; i-- {
+		revisions = append(revisions, m.copyOf(&stored[i]))
+	}
+	return revisions, nil
+}
+
+// remove deletes every revision of the given snippet.
+func (m *SnippetRevisionModel) remove(snippetID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.revisions, snippetID)
+}
@@ -0,0 +1,285 @@
+package mocks
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SnippetModel is an in-memory models.SnippetRepository. Create it with NewStore.
+type SnippetModel struct {
+	mu       sync.Mutex
+	snippets map[int]*models.Snippet
+	nextID   int
+
+	users     *UserModel
+	tags      *TagModel
+	revisions *SnippetRevisionModel
+}
+
+// copyOf returns a copy of a stored snippet with the author's name filled in, so that callers can change what they
+// are given without changing what is stored.
+func (m *SnippetModel) copyOf(s *models.Snippet) *models.Snippet {
+	c := *s
+	c.Tags = nil
+	if u, err := m.users.Get(s.CreatedBy.ID); err == nil {
+		c.CreatedBy.Name = u.Name
+	}
+	return &c
+}
+
+// find returns copies of every stored snippet for which keep returns true, newest first.
+func (m *SnippetModel) find(keep func(*models.Snippet) bool) []*models.Snippet {
+	m.mu.Lock()
+	var stored []*models.Snippet
+	for _, s := range m.snippets {
+		if keep(s) {
+			c := *s
+			stored = append(stored, &c)
+		}
+	}
+	m.mu.Unlock()
+
+	// The copies are made outside the lock, as filling in the author's name uses the users mock.
+	snippets := make([]*models.Snippet, len(stored))
+	for i, s := range stored {
+		snippets[i] = m.copyOf(s)
+	}
+	sortNewestFirst(snippets)
+	return snippets
+}
+
+func (m *SnippetModel) Insert(title, content, language, visibility string, expires time.Time, userID int,
+	burnAfterReading bool, tags []string) (int, error) {
+	m.mu.Lock()
+	m.nextID++
+	id := m.nextID
+	m.snippets[id] = &models.Snippet{
+		ID:               id,
+		Title:            title,
+		Content:          content,
+		Language:         language,
+		Visibility:       visibility,
+		Slug:             fmt.Sprintf("mock-slug-%d", id),
+		BurnAfterReading: burnAfterReading,
+		Created:          time.Now().UTC().Truncate(time.Second),
+		Expires:          expires.UTC().Truncate(time.Second),
+		CreatedBy:        models.User{ID: userID},
+	}
+	m.mu.Unlock()
+
+	m.revisions.insert(id, title, content, userID)
+	_ = m.tags.set(id, tags)
+	return id, nil
+}
+
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	snippets := m.find(func(s *models.Snippet) bool { return s.ID == id && live(s) })
+	if len(snippets) == 0 {
+		return nil, models.ErrNoRecord
+	}
+	return snippets[0], nil
+}
+
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	snippets := m.find(func(s *models.Snippet) bool { return s.Slug == slug && live(s) })
+	if len(snippets) == 0 {
+		return nil, models.ErrNoRecord
+	}
+	return snippets[0], nil
+}
+
+// update calls fn with the stored live snippet with the given id, or returns ErrNoRecord if there isn't one.
+func (m *SnippetModel) update(id int, fn func(*models.Snippet) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if !ok || !live(s) {
+		return models.ErrNoRecord
+	}
+	return fn(s)
+}
+
+func (m *SnippetModel) Update(id int, title, content, language, visibility string, expires time.Time, tags []string,
+	userID int) error {
+	var changed bool
+	err := m.update(id, func(s *models.Snippet) error {
+		changed = s.Title != title || s.Content != content
+		s.Title, s.Content, s.Language, s.Visibility = title, content, language, visibility
+		s.Expires = expires.UTC().Truncate(time.Second)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		m.revisions.insert(id, title, content, userID)
+	}
+	return m.tags.set(id, tags)
+}
+
+func (m *SnippetModel) Extend(id int, expires time.Time) error {
+	return m.update(id, func(s *models.Snippet) error {
+		expires = expires.UTC().Truncate(time.Second)
+		if !s.Expires.Before(expires) {
+			return models.ErrNoRecord
+		}
+		s.Expires = expires
+		return nil
+	})
+}
+
+func (m *SnippetModel) UpdateContent(id int, title, content string, userID int) error {
+	var changed bool
+	err := m.update(id, func(s *models.Snippet) error {
+		changed = s.Title != title || s.Content != content
+		s.Title, s.Content = title, content
+		return nil
+	})
+	if err != nil || !changed {
+		return err
+	}
+
+	m.revisions.insert(id, title, content, userID)
+	return nil
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	m.mu.Lock()
+	_, ok := m.snippets[id]
+	delete(m.snippets, id)
+	m.mu.Unlock()
+
+	if !ok {
+		return models.ErrNoRecord
+	}
+	m.tags.remove(id)
+	m.revisions.remove(id)
+	return nil
+}
+
+func (m *SnippetModel) Consume(id int) error {
+	err := m.update(id, func(s *models.Snippet) error {
+		switch {
+		case !s.BurnAfterReading:
+			return models.ErrNoRecord
+		case s.ReadAt.Valid:
+			return models.ErrAlreadyRead
+		}
+		s.ReadAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		s.Content = ""
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.revisions.remove(id)
+	return nil
+}
+
+// PurgeExpired removes up to limit expired snippets. Archived snippets aren't kept anywhere.
+func (m *SnippetModel) PurgeExpired(limit int, archive bool) (int, error) {
+	m.mu.Lock()
+	var ids []int
+	for id, s := range m.snippets {
+		if len(ids) < limit && !live(s) {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		delete(m.snippets, id)
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		m.tags.remove(id)
+		m.revisions.remove(id)
+	}
+	return len(ids), nil
+}
+
+func (m *SnippetModel) GetLatest(page, pageSize int) ([]*models.Snippet, models.Metadata, error) {
+	snippets, metadata := paginate(m.find(listed), page, pageSize)
+	return snippets, metadata, nil
+}
+
+func (m *SnippetModel) GetLatestAfter(after, limit int) ([]*models.Snippet, bool, error) {
+	limit = models.ClampPageSize(limit)
+
+	snippets := m.find(func(s *models.Snippet) bool { return listed(s) && (after < 1 || s.ID < after) })
+	if len(snippets) > limit {
+		return snippets[:limit], true, nil
+	}
+	return snippets, false, nil
+}
+
+func (m *SnippetModel) CountLive() (int, error) {
+	return len(m.find(listed)), nil
+}
+
+func (m *SnippetModel) GetByUser(userID, page, pageSize int) ([]*models.Snippet, models.Metadata, error) {
+	snippets, metadata := paginate(m.find(func(s *models.Snippet) bool {
+		return live(s) && s.CreatedBy.ID == userID
+	}), page, pageSize)
+	return snippets, metadata, nil
+}
+
+func (m *SnippetModel) GetByTag(tag string, page, pageSize int) ([]*models.Snippet, models.Metadata, error) {
+	tagged := m.tags.snippetsWith(tag)
+
+	snippets, metadata := paginate(m.find(func(s *models.Snippet) bool {
+		return listed(s) && tagged[s.ID]
+	}), page, pageSize)
+	return snippets, metadata, nil
+}
+
+// Search matches every term against the title and content with a case-insensitive substring match, which is close
+// enough to the LIKE fallback of the database model for testing.
+func (m *SnippetModel) Search(f models.SearchFilters) ([]*models.Snippet, models.Metadata, error) {
+	terms := models.ParseSearchQuery(f.Query)
+
+	var tagged map[int]bool
+	if f.Tag != "" {
+		tagged = m.tags.snippetsWith(f.Tag)
+	}
+
+	candidates := m.find(listed)
+
+	var matches []*models.Snippet
+	for _, s := range candidates {
+		text := strings.ToLower(s.Title + "\n" + s.Content)
+
+		ok := true
+		for _, t := range terms {
+			ok = ok && strings.Contains(text, strings.ToLower(t.Text))
+		}
+		ok = ok && (f.Author == "" || s.CreatedBy.Name == f.Author)
+		ok = ok && (tagged == nil || tagged[s.ID])
+		ok = ok && (f.From.IsZero() || !s.Created.Before(f.From))
+		ok = ok && (f.To.IsZero() || s.Created.Before(f.To.AddDate(0, 0, 1)))
+
+		if ok {
+			matches = append(matches, s)
+		}
+	}
+
+	snippets, metadata := paginate(matches, f.Page, f.PageSize)
+	return snippets, metadata, nil
+}
+
+// Expire moves the expiry time of the snippet with the given id into the past, so tests can check how expired
+// snippets are treated.
+func (m *SnippetModel) Expire(id int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.snippets[id]; ok {
+		s.Expires = time.Now().UTC().Add(-time.Minute)
+	}
+}
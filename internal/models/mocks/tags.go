@@ -0,0 +1,120 @@
+package mocks
+
+import (
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// TagModel is an in-memory models.TagRepository. Create it with NewStore.
+type TagModel struct {
+	mu   sync.Mutex
+	tags map[int][]string
+	// ids gives each tag name an ID the first time it is used, as the tags table does.
+	ids map[string]int
+
+	snippets *SnippetModel
+}
+
+// set replaces the tags on the given snippet. It is called by SnippetModel, as the database models set tags along
+// with the snippet.
+func (m *TagModel) set(snippetID int, names []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(names) == 0 {
+		delete(m.tags, snippetID)
+		return nil
+	}
+	sorted := slices.Clone(names)
+	slices.Sort(sorted)
+	m.tags[snippetID] = slices.Compact(sorted)
+
+	for _, name := range m.tags[snippetID] {
+		if _, ok := m.ids[name]; !ok {
+			m.ids[name] = len(m.ids) + 1
+		}
+	}
+	return nil
+}
+
+func (m *TagModel) GetForSnippets(snippetIDs []int) (map[int][]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tags := make(map[int][]string)
+	for _, id := range snippetIDs {
+		if names, ok := m.tags[id]; ok {
+			tags[id] = slices.Clone(names)
+		}
+	}
+	return tags, nil
+}
+
+func (m *TagModel) Attach(snippets ...*models.Snippet) error {
+	ids := make([]int, len(snippets))
+	for i, s := range snippets {
+		ids[i] = s.ID
+	}
+
+	tags, err := m.GetForSnippets(ids)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range snippets {
+		s.Tags = tags[s.ID]
+	}
+	return nil
+}
+
+func (m *TagModel) GetAll() ([]*models.Tag, error) {
+	// Only tags on listed snippets are counted, as in the database model. The listed snippets are found before
+	// taking the lock, as the snippets mock takes its own.
+	listedIDs := make(map[int]bool)
+	for _, s := range m.snippets.find(listed) {
+		listedIDs[s.ID] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := make(map[string]int)
+	for id, names := range m.tags {
+		if listedIDs[id] {
+			for _, name := range names {
+				counts[name]++
+			}
+		}
+	}
+
+	var tags []*models.Tag
+	for name, count := range counts {
+		tags = append(tags, &models.Tag{ID: m.ids[name], Name: name, Count: count})
+	}
+	slices.SortFunc(tags, func(a, b *models.Tag) int { return strings.Compare(a.Name, b.Name) })
+	return tags, nil
+}
+
+// snippetsWith returns the IDs of the snippets with the given tag.
+func (m *TagModel) snippetsWith(tag string) map[int]bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make(map[int]bool)
+	for id, names := range m.tags {
+		if slices.Contains(names, tag) {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// remove deletes the tags on the given snippet.
+func (m *TagModel) remove(snippetID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.tags, snippetID)
+}
@@ -0,0 +1,76 @@
+package mocks
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TokenModel is an in-memory models.TokenRepository. Create it with NewStore. The plain-text tokens it returns are
+// predictable, and are stored as they are rather than hashed.
+type TokenModel struct {
+	mu     sync.Mutex
+	tokens map[string]*models.Token
+	nextID int
+}
+
+func (m *TokenModel) Insert(userID int, name string, scope string, expires int) (string, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	now := time.Now().UTC().Truncate(time.Second)
+	t := &models.Token{ID: m.nextID, UserID: userID, Name: name, Scope: scope, Created: now}
+	if expires != 0 {
+		t.Expires = sql.NullTime{Time: now.AddDate(0, 0, expires), Valid: true}
+	}
+
+	plaintext := fmt.Sprintf("sbx_mock%d", m.nextID)
+	m.tokens[plaintext] = t
+	return plaintext, t.ID, nil
+}
+
+func (m *TokenModel) Authenticate(plaintext string) (*models.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[plaintext]
+	if !ok || (t.Expires.Valid && !t.Expires.Time.After(time.Now())) {
+		return nil, models.ErrInvalidToken
+	}
+	t.LastUsed = sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true}
+
+	c := *t
+	return &c, nil
+}
+
+func (m *TokenModel) GetAllForUser(userID int) ([]*models.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var tokens []*models.Token
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			c := *t
+			tokens = append(tokens, &c)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+	return tokens, nil
+}
+
+func (m *TokenModel) Delete(id int, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for plaintext, t := range m.tokens {
+		if t.ID == id && t.UserID == userID {
+			delete(m.tokens, plaintext)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
@@ -0,0 +1,99 @@
+package mocks
+
+import (
+	"errors"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UserModel is an in-memory models.UserRepository. Create it with NewStore.
+type UserModel struct {
+	mu     sync.Mutex
+	users  map[int]*models.User
+	nextID int
+}
+
+func (m *UserModel) Insert(name, email, password string) error {
+	// The lowest cost is used, as the tests don't need the protection of a slow hash.
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if strings.EqualFold(u.Email, email) {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	m.nextID++
+	m.users[m.nextID] = &models.User{
+		ID:             m.nextID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC().Truncate(time.Second),
+		Active:         1,
+	}
+	return nil
+}
+
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email != email {
+			continue
+		}
+
+		err := bcrypt.CompareHashAndPassword(u.HashedPassword, []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		} else if err != nil {
+			return 0, err
+		}
+		if u.Active != 1 {
+			return 0, models.ErrInactiveUser
+		}
+		return u.ID, nil
+	}
+	return 0, models.ErrInvalidCredentials
+}
+
+func (m *UserModel) Exists(id int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	return ok && u.Active == 1, nil
+}
+
+func (m *UserModel) Get(id int) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	c := *u
+	c.HashedPassword = nil
+	return &c, nil
+}
+
+// Deactivate marks the user with the given ID as inactive, so tests can check that they are logged out.
+func (m *UserModel) Deactivate(id int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if u, ok := m.users[id]; ok {
+		u.Active = 0
+	}
+}
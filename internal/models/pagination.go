@@ -0,0 +1,55 @@
+package models
+
+// MaxPageSize is the largest number of records which can be requested in a single page, however the page size is
+// configured.
+const MaxPageSize = 100
+
+// Metadata describes one page of a page-based listing.
+type Metadata struct {
+	CurrentPage  int `json:"current_page"`
+	PageSize     int `json:"page_size"`
+	LastPage     int `json:"last_page"`
+	TotalRecords int `json:"total_records"`
+}
+
+// HasPrevious reports whether there is a page before the current page.
+func (m Metadata) HasPrevious() bool {
+	return m.CurrentPage > 1
+}
+
+// HasNext reports whether there is a page after the current page.
+func (m Metadata) HasNext() bool {
+	return m.CurrentPage < m.LastPage
+}
+
+// PreviousPage returns the number of the page before the current page.
+func (m Metadata) PreviousPage() int {
+	return m.CurrentPage - 1
+}
+
+// NextPage returns the number of the page after the current page.
+func (m Metadata) NextPage() int {
+	return m.CurrentPage + 1
+}
+
+// ClampPageSize returns size limited to between 1 and MaxPageSize, so a bad configuration value or query string
+// parameter can't request an unbounded page.
+func ClampPageSize(size int) int {
+	return min(max(size, 1), MaxPageSize)
+}
+
+// offset returns the number of records to skip to reach the given page.
+func offset(page, pageSize int) int {
+	return (page - 1) * pageSize
+}
+
+// CalculateMetadata works out the metadata for the given page from the total number of matching records. An empty
+// listing still has one (empty) page.
+func CalculateMetadata(totalRecords, page, pageSize int) Metadata {
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		LastPage:     max((totalRecords+pageSize-1)/pageSize, 1),
+		TotalRecords: totalRecords,
+	}
+}
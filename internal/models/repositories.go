@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// The repository interfaces describe everything the application does with each table, so that handlers depend on
+// the behaviour rather than on the MySQL-backed models. The models in this package implement them against the
+// database, and the models in the mocks package implement them in memory for tests.
+
+// SnippetRepository stores snippets.
+type SnippetRepository interface {
+	Insert(title, content, language, visibility string, expires time.Time, userID int, burnAfterReading bool,
+		tags []string) (int, error)
+	Get(id int) (*Snippet, error)
+	GetBySlug(slug string) (*Snippet, error)
+	Update(id int, title, content, language, visibility string, expires time.Time, tags []string, userID int) error
+	Extend(id int, expires time.Time) error
+	UpdateContent(id int, title, content string, userID int) error
+	Delete(id int) error
+	Consume(id int) error
+	PurgeExpired(limit int, archive bool) (int, error)
+	GetLatest(page, pageSize int) ([]*Snippet, Metadata, error)
+	GetLatestAfter(after, limit int) ([]*Snippet, bool, error)
+	CountLive() (int, error)
+	GetByUser(userID, page, pageSize int) ([]*Snippet, Metadata, error)
+	GetByTag(tag string, page, pageSize int) ([]*Snippet, Metadata, error)
+	Search(f SearchFilters) ([]*Snippet, Metadata, error)
+}
+
+// UserRepository stores users and checks their credentials.
+type UserRepository interface {
+	Insert(name, email, password string) error
+	Authenticate(email, password string) (int, error)
+	Exists(id int) (bool, error)
+	Get(id int) (*User, error)
+}
+
+// RevisionRepository reads the revision history of snippets. Revisions are recorded by the SnippetRepository, along
+// with the change they copy.
+type RevisionRepository interface {
+	Get(snippetID int, revision int) (*SnippetRevision, error)
+	GetAll(snippetID int) ([]*SnippetRevision, error)
+}
+
+// TokenRepository stores personal API tokens.
+type TokenRepository interface {
+	Insert(userID int, name string, scope string, expires int) (string, int, error)
+	Authenticate(plaintext string) (*Token, error)
+	GetAllForUser(userID int) ([]*Token, error)
+	Delete(id int, userID int) error
+}
+
+// TagRepository reads the tags on snippets. Tags are set by the SnippetRepository, along with the snippet.
+type TagRepository interface {
+	GetForSnippets(snippetIDs []int) (map[int][]string, error)
+	Attach(snippets ...*Snippet) error
+	GetAll() ([]*Tag, error)
+}
+
+// SessionRepository purges the sessions stored by the session manager.
+type SessionRepository interface {
+	DeleteExpired() (int, error)
+}
+
+// Check at compile time that the database models implement the interfaces.
+var (
+	_ SnippetRepository  = (*SnippetModel)(nil)
+	_ UserRepository     = (*UserModel)(nil)
+	_ RevisionRepository = (*SnippetRevisionModel)(nil)
+	_ TokenRepository    = (*TokenModel)(nil)
+	_ TagRepository      = (*TagModel)(nil)
+	_ SessionRepository  = (*SessionModel)(nil)
+)
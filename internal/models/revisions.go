@@ -0,0 +1,121 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SnippetRevision is an immutable copy of a snippet's title and content, recorded each time the snippet is
+// created or changed. Revisions are numbered from 1 for each snippet.
+type SnippetRevision struct {
+	ID        int
+	SnippetID int
+	Revision  int
+	Title     string
+	Content   string
+	Created   time.Time
+	CreatedBy User
+}
+
+type SnippetRevisionModel struct {
+	DB *DB
+}
+
+// revisionColumns lists the columns selected for a SnippetRevision, in the order expected by scanRevision.
+const revisionColumns = `r.id, r.snippet_id, r.revision, r.title, r.content, r.created,
+             COALESCE(u.id, 0), COALESCE(u.name, '')
+             FROM snippet_revisions r LEFT JOIN users u ON u.id = r.user_id`
+
+// scanRevision copies the columns listed in revisionColumns into a new SnippetRevision.
+func scanRevision(row scanner) (*SnippetRevision, error) {
+	r := &SnippetRevision{}
+
+	err := row.Scan(&r.ID, &r.SnippetID, &r.Revision, &r.Title, &r.Content, &r.Created,
+		&r.CreatedBy.ID, &r.CreatedBy.Name)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// insertRevision records a new revision of the given snippet, made by the given user, in tx and returns its revision
+// number. Revisions are only recorded alongside the change to the snippet they copy, by SnippetModel.
+func insertRevision(tx *Tx, snippetID int, title string, content string, userID int) (int, error) {
+	// The revision number is calculated in the same statement as the insert. If two revisions of the same snippet
+	// are saved at exactly the same time, the UNIQUE constraint on (snippet_id, revision) makes one of them fail
+	// rather than silently recording two revisions with the same number.
+	stmt := `INSERT INTO snippet_revisions (snippet_id, revision, title, content, user_id, created)
+             VALUES (?, (SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?), ?, ?, ?,
+             UTC_TIMESTAMP())`
+	args := []any{snippetID, snippetID, title, content, userID}
+
+	// MySQL doesn't allow a subquery on the table being inserted into, but does allow INSERT ... SELECT from it.
+	// Postgres can't work out the types of placeholders in the select list, so the other databases use the subquery.
+	if tx.dialect == MySQL {
+		stmt = `INSERT INTO snippet_revisions (snippet_id, revision, title, content, user_id, created)
+                SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ?, ?, UTC_TIMESTAMP()
+                FROM snippet_revisions WHERE snippet_id = ?`
+		args = []any{snippetID, title, content, userID, snippetID}
+	}
+
+	_, err := tx.Exec(stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	var revision int
+
+	stmt = `SELECT MAX(revision) FROM snippet_revisions WHERE snippet_id = ?`
+
+	err = tx.QueryRow(stmt, snippetID).Scan(&revision)
+	if err != nil {
+		return 0, err
+	}
+	return revision, nil
+}
+
+// Get returns a single revision of the given snippet, or ErrNoRecord if it doesn't exist.
+func (m *SnippetRevisionModel) Get(snippetID int, revision int) (*SnippetRevision, error) {
+	stmt := `SELECT ` + revisionColumns + ` WHERE r.snippet_id = ? AND r.revision = ?`
+
+	r, err := scanRevision(m.DB.QueryRow(stmt, snippetID, revision))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// GetAll returns every revision of the given snippet, newest first.
+func (m *SnippetRevisionModel) GetAll(snippetID int) ([]*SnippetRevision, error) {
+	stmt := `SELECT ` + revisionColumns + ` WHERE r.snippet_id = ? ORDER BY r.revision DESC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var revisions []*SnippetRevision
+
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
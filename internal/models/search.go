@@ -0,0 +1,217 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchTerm is a single term of a search query. A Phrase term was written in double quotes and must match exactly,
+// and a Prefix term was written with a trailing * and matches any word starting with Text.
+type SearchTerm struct {
+	Text   string
+	Phrase bool
+	Prefix bool
+}
+
+// SearchFilters holds a search query along with the filters which narrow it down. Zero values mean "don't filter".
+// From and To are inclusive, and compared with the date the snippet was created.
+type SearchFilters struct {
+	Query    string
+	Author   string
+	Tag      string
+	From     time.Time
+	To       time.Time
+	Page     int
+	PageSize int
+}
+
+// ParseSearchQuery splits a search query into terms. Text in double quotes is a phrase, a word ending in * is a
+// prefix, and everything else is split into plain words. Characters which have a special meaning in MySQL boolean
+// full-text queries are dropped from words, so that user input can't change the meaning of the query.
+func ParseSearchQuery(q string) []SearchTerm {
+	var terms []SearchTerm
+
+	for q != "" {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if q == "" {
+			break
+		}
+
+		if q[0] == '"' {
+			// An unterminated phrase runs to the end of the query.
+			phrase, rest, _ := strings.Cut(q[1:], `"`)
+			q = rest
+			phrase = strings.Join(strings.FieldsFunc(phrase, isSearchSeparator), " ")
+			if phrase != "" {
+				terms = append(terms, SearchTerm{Text: phrase, Phrase: true})
+			}
+			continue
+		}
+
+		end := strings.IndexFunc(q, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		if end == -1 {
+			end = len(q)
+		}
+		word := q[:end]
+		q = q[end:]
+
+		prefix := strings.HasSuffix(word, "*")
+		for _, part := range strings.FieldsFunc(word, isSearchSeparator) {
+			terms = append(terms, SearchTerm{Text: part})
+		}
+		// Only the last part of a word such as "net/ht*" is a prefix.
+		if prefix && len(terms) > 0 && !terms[len(terms)-1].Phrase {
+			terms[len(terms)-1].Prefix = true
+		}
+	}
+
+	return terms
+}
+
+// isSearchSeparator reports whether r separates words in a search query. Anything other than letters, digits and
+// a few characters common in code identifiers is treated as a separator, which also removes the MySQL boolean
+// full-text operators + - < > ( ) ~ * " @.
+func isSearchSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+}
+
+// ftMinTokenSize is the default innodb_ft_min_token_size: shorter words aren't in MySQL full-text indexes.
+const ftMinTokenSize = 3
+
+// ftStopwords are MySQL's default InnoDB full-text stopwords, which aren't in full-text indexes either.
+var ftStopwords = map[string]bool{
+	"a": true, "about": true, "an": true, "are": true, "as": true, "at": true, "be": true, "by": true, "com": true,
+	"de": true, "en": true, "for": true, "from": true, "how": true, "i": true, "in": true, "is": true, "it": true,
+	"la": true, "of": true, "on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"what": true, "when": true, "where": true, "who": true, "will": true, "with": true, "und": true, "www": true,
+}
+
+// indexed reports whether a term can be found with a MySQL full-text search. Requiring a word which is too short or
+// a stopword makes the whole query match nothing, as the word is never in the index. Prefix terms are never
+// ignored by MySQL, so they always count as indexed.
+func indexed(t SearchTerm) bool {
+	if t.Prefix {
+		return true
+	}
+	for _, word := range strings.Fields(t.Text) {
+		if utf8.RuneCountInString(word) < ftMinTokenSize || ftStopwords[strings.ToLower(word)] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTerms splits terms into those which can be matched with a MySQL full-text search and those which have to be
+// matched with LIKE instead.
+func splitTerms(terms []SearchTerm) (fullText, like []SearchTerm) {
+	for _, t := range terms {
+		if indexed(t) {
+			fullText = append(fullText, t)
+		} else {
+			like = append(like, t)
+		}
+	}
+	return fullText, like
+}
+
+// booleanQuery builds a MySQL boolean mode full-text query which requires every term to match. The terms should all
+// be indexed (see splitTerms).
+func booleanQuery(terms []SearchTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, t := range terms {
+		switch {
+		case t.Phrase:
+			parts = append(parts, `+"`+t.Text+`"`)
+		case t.Prefix:
+			parts = append(parts, "+"+t.Text+"*")
+		default:
+			parts = append(parts, "+"+t.Text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// likePattern returns a LIKE pattern which matches text anywhere in a column, escaping the LIKE wildcards with !
+// (see ESCAPE '!' in Search). A backslash isn't used as the escape character because databases disagree on how to
+// write it in a string literal. It is used when full-text search isn't available, where every term (including
+// phrases and prefixes) becomes a case-insensitive substring match.
+func likePattern(text string) string {
+	r := strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+	return "%" + r.Replace(text) + "%"
+}
+
+// Search returns one page of the live, public snippets matching the given filters, along with the pagination
+// metadata and a possible error. Every term in the query must match the title or content. With full-text search
+// enabled, the terms in the full-text index are matched with MATCH and the results ordered by relevance; any other
+// terms are matched with LIKE. Without it, or if no term is in the index, every term is matched with LIKE and the
+// results are ordered newest first.
+func (m *SnippetModel) Search(f SearchFilters) ([]*Snippet, Metadata, error) {
+	pageSize := ClampPageSize(f.PageSize)
+	page := max(f.Page, 1)
+	terms := ParseSearchQuery(f.Query)
+
+	// Search never finds unlisted, private or burn after reading snippets, even for their author.
+	where := []string{"s.expires > UTC_TIMESTAMP()", "s.visibility = 'public'", "s.burn_after_reading = FALSE"}
+	var args []any
+	order := "s.id DESC"
+	var orderArgs []any
+
+	likeTerms := terms
+	if m.FullText {
+		var fullTextTerms []SearchTerm
+		fullTextTerms, likeTerms = splitTerms(terms)
+		if len(fullTextTerms) > 0 {
+			q := booleanQuery(fullTextTerms)
+			where = append(where, "MATCH(s.title, s.content) AGAINST(? IN BOOLEAN MODE)")
+			args = append(args, q)
+			order = "MATCH(s.title, s.content) AGAINST(? IN BOOLEAN MODE) DESC, s.id DESC"
+			orderArgs = append(orderArgs, q)
+		}
+	}
+	for _, t := range likeTerms {
+		pattern := likePattern(strings.ToLower(t.Text))
+		where = append(where, `(LOWER(s.title) LIKE ? ESCAPE '!' OR LOWER(s.content) LIKE ? ESCAPE '!')`)
+		args = append(args, pattern, pattern)
+	}
+
+	if f.Author != "" {
+		where = append(where, "u.name = ?")
+		args = append(args, f.Author)
+	}
+	if f.Tag != "" {
+		where = append(where, `EXISTS (SELECT 1 FROM snippet_tags st INNER JOIN tags t ON t.id = st.tag_id
+             WHERE st.snippet_id = s.id AND t.name = ?)`)
+		args = append(args, f.Tag)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "s.created >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		// To is a date, so everything created before the start of the following day matches.
+		where = append(where, "s.created < ?")
+		args = append(args, f.To.UTC().AddDate(0, 0, 1))
+	}
+
+	whereClause := " WHERE " + strings.Join(where, " AND ")
+
+	// The author filter needs the users table, so the count joins it in the same way as snippetColumns.
+	var total int
+	stmt := `SELECT COUNT(*) FROM snippets s LEFT JOIN users u ON u.id = s.user_id` + whereClause
+	err := m.DB.QueryRow(stmt, args...).Scan(&total)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	stmt = `SELECT ` + snippetColumns + whereClause + ` ORDER BY ` + order + ` LIMIT ? OFFSET ?`
+	queryArgs := append(append(args, orderArgs...), pageSize, offset(page, pageSize))
+
+	snippets, err := m.query(stmt, queryArgs...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return snippets, CalculateMetadata(total, page, pageSize), nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBooleanQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+		like  []string
+	}{
+		{"words", "silent pond", "+silent +pond", nil},
+		{"two letters", "go", "", []string{"go"}},
+		{"stopword", "The pond", "+pond", []string{"The"}},
+		{"prefix", "go*", "+go*", nil},
+		{"phrase", `"old silent pond"`, `+"old silent pond"`, nil},
+		{"phrase with a short word", `"an old pond"`, "", []string{"an old pond"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullText, like := splitTerms(ParseSearchQuery(tt.query))
+			if got := booleanQuery(fullText); got != tt.want {
+				t.Errorf("got query %q; want %q", got, tt.want)
+			}
+
+			var got []string
+			for _, term := range like {
+				got = append(got, term.Text)
+			}
+			if !slices.Equal(got, tt.like) {
+				t.Errorf("got LIKE terms %q; want %q", got, tt.like)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+// SessionModel manages the sessions table used by the scs session store. The store only reads and writes
+// individual sessions; expired ones are purged through DeleteExpired by the reaper.
+type SessionModel struct {
+	DB *DB
+}
+
+// DeleteExpired removes every expired session, and returns how many were removed.
+func (m *SessionModel) DeleteExpired() (int, error) {
+	// Each session store keeps the expiry time in its own way: MySQL's in a TIMESTAMP(6) holding UTC, Postgres' in
+	// a TIMESTAMPTZ, and SQLite's as a Julian day number.
+	var stmt string
+	switch m.DB.Dialect {
+	case Postgres:
+		stmt = `DELETE FROM sessions WHERE expiry < current_timestamp`
+	case SQLite:
+		stmt = `DELETE FROM sessions WHERE expiry < julianday('now')`
+	default:
+		stmt = `DELETE FROM sessions WHERE expiry < UTC_TIMESTAMP(6)`
+	}
+
+	result, err := m.DB.Exec(stmt)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
@@ -1,22 +1,93 @@
 package models
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"errors"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// The visibility levels of a snippet. Public snippets are listed and reachable by their ID. Unlisted snippets aren't
+// listed, and are only reachable by their slug. Private snippets aren't listed, and are only reachable by their
+// author, by ID.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// NeverExpires is the expiry time stored for snippets which never expire. A far future time is used rather than
+// NULL so that every query can keep checking for live snippets with expires > UTC_TIMESTAMP().
+var NeverExpires = time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
+
+// listedOnly is added to the WHERE clause of every query which lists snippets, so only public snippets are listed.
+// Burn after reading snippets are never listed, whatever their visibility, as anyone opening one would destroy it.
+const listedOnly = ` AND s.visibility = 'public' AND s.burn_after_reading = FALSE`
+
 type Snippet struct {
-	ID        int
-	Title     string
-	Content   string
-	Created   time.Time
-	Expires   time.Time
-	CreatedBy User
+	ID      int
+	Title   string
+	Content string
+	// Language is the alias of the language used for syntax highlighting, or empty for plain text.
+	Language   string
+	Visibility string
+	// Slug is a long random string which is used instead of the ID to reach unlisted snippets.
+	Slug string
+	// BurnAfterReading snippets can only be read once. ReadAt is set, and the content is cleared, when they are.
+	BurnAfterReading bool
+	ReadAt           sql.NullTime
+	Created          time.Time
+	Expires          time.Time
+	CreatedBy        User
+	// Tags is not loaded by the SnippetModel methods; use TagModel.Attach to fill it in.
+	Tags []string
+}
+
+// Ref returns the value used to refer to the snippet in URLs: the slug for unlisted snippets, and the ID otherwise.
+func (s *Snippet) Ref() string {
+	if s.Visibility == VisibilityUnlisted {
+		return s.Slug
+	}
+	return strconv.Itoa(s.ID)
+}
+
+// NeverExpires reports whether the snippet has been set to never expire.
+func (s *Snippet) NeverExpires() bool {
+	return !s.Expires.Before(NeverExpires)
+}
+
+// VisibleTo reports whether the snippet can be reached by its ID by the user with the given ID, which is 0 for
+// anonymous users. Unlisted snippets can never be reached by ID, only by slug.
+func (s *Snippet) VisibleTo(userID int) bool {
+	switch s.Visibility {
+	case VisibilityUnlisted:
+		return false
+	case VisibilityPrivate:
+		return userID != 0 && s.CreatedBy.ID == userID
+	default:
+		return true
+	}
+}
+
+// newSlug returns a new random slug. 18 random bytes make 24 URL-safe characters, which can't be guessed.
+func newSlug() (string, error) {
+	b := make([]byte, 18)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
 type SnippetModel struct {
-	DB *sql.DB
+	DB *DB
+	// FullText enables MySQL FULLTEXT search in Search(). It requires the FULLTEXT index on (title, content);
+	// without it, Search() falls back to LIKE matching, which works on any database but can't rank results.
+	FullText bool
 }
 
 // Remember that using a receiver function is the same as declaring a method. These functions below
@@ -31,57 +102,104 @@ type SnippetModel struct {
 
 // On the other hand, we're mixing business logic with the database in a pattern referred to as the "Active Record"
 // pattern. This pattern isn't bad for a small learning app like this, but it tightly couples the application and
-// the database and should be replaced by something more robust that decouples the database from the application,
-// like the "Repository" pattern.
+// the database. To loosen that coupling the handlers only see the models through the repository interfaces in
+// repositories.go (the "Repository" pattern), so they can be tested against the in-memory models in the mocks
+// package.
+
+// snippetColumns lists the columns selected for a Snippet, in the order expected by scanSnippet. The author is joined
+// in from the users table; a LEFT JOIN is used so snippets created before authorship was recorded are still returned.
+const snippetColumns = `s.id, s.title, s.content, s.language, s.visibility, s.slug, s.burn_after_reading,
+             s.read_at, s.created, s.expires, COALESCE(u.id, 0), COALESCE(u.name, '')
+             FROM snippets s LEFT JOIN users u ON u.id = s.user_id`
+
+// scanner is satisfied by both *sql.Row and *sql.Rows, so scanSnippet can be used for single and multi-row queries.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSnippet copies the columns listed in snippetColumns into a new Snippet.
+func scanSnippet(row scanner) (*Snippet, error) {
+	// Initialize a pointer to a new zeroed Snippet struct
+	s := &Snippet{}
+
+	// The arguments to Scan() are *pointers* to the target for the copied data and the number of
+	// arguments must be exactly the same as the number of columns returned by the statement.
+	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Language, &s.Visibility, &s.Slug, &s.BurnAfterReading, &s.ReadAt,
+		&s.Created, &s.Expires, &s.CreatedBy.ID, &s.CreatedBy.Name)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Insert takes in a title, some content, the content's language and visibility, the time it expires (NeverExpires if
+// it shouldn't), the ID of the user creating the snippet, whether it should be burnt after reading and its tags, and
+// returns an id and possibly an error. The snippet is saved along with its first revision and its tags in a single
+// transaction, so it is never seen without them.
+func (m *SnippetModel) Insert(title, content, language, visibility string, expires time.Time, userID int,
+	burnAfterReading bool, tags []string) (int, error) {
+	// Every snippet gets a slug, so that it can be made unlisted later without changing its other URLs.
+	slug, err := newSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
-// Insert takes in a title, some content, and an expiration number of days and returns an id and possibly an error
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
 	// SQL statement that will be executed; use ? placeholders for values
 	// not interpolation of variables to guard against injection attacks
-	stmt := `INSERT INTO snippets (title, content, created, expires) VALUES (?, ?,
-            UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
-
-	// Use Exec() on the embedded connection pool to execute the statement. This returns a sql.Result
-	// type, which contains basic information about what happened when the statement was executed.
-	// Exec() compiles a prepared statement and stores it, then, in a next step, passes parameter values (?) to
-	// the database where the DB executes the prepared statement using the parameters. Because the database is
-	// getting the parameters after the statement is compiled, they're treated as pure data and can't change the intent
-	// of the statement, so if a user inputs a statement intended as an injection attack, it will simply be
-	// treated is any other query parameter, it can't actually be executed. This is required when preparing your
-	// own sql statements as opposed to using methods provided by an ORM/ODM.
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	stmt := `INSERT INTO snippets (title, content, language, visibility, slug, burn_after_reading, created, expires,
+            user_id) VALUES (?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?, ?)`
+
+	// Use insert() on the transaction to execute the statement and get the ID of the newly inserted record,
+	// which comes from the result's LastInsertId() method, or from a RETURNING clause on databases which don't
+	// support it. Like Exec(), it compiles a prepared statement and stores it, then, in a next step, passes
+	// parameter values (?) to the database where the DB executes the prepared statement using the parameters.
+	// Because the database is getting the parameters after the statement is compiled, they're treated as pure data
+	// and can't change the intent of the statement, so if a user inputs a statement intended as an injection attack,
+	// it will simply be treated is any other query parameter, it can't actually be executed. This is required when
+	// preparing your own sql statements as opposed to using methods provided by an ORM/ODM.
+	id, err := tx.insert(stmt, title, content, language, visibility, slug, burnAfterReading, expires.UTC(), userID)
 	if err != nil {
 		return 0, err
 	}
 
-	// Use the LastInsertId() method on the result to get the ID of the newly inserted record.
-	id, err := result.LastInsertId()
+	_, err = insertRevision(tx, id, title, content, userID)
 	if err != nil {
 		return 0, err
 	}
 
-	// The ID returned has the type of int64, so it's converted to an int before returning.
-	return int(id), nil
+	err = setTags(tx, id, tags)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
-// Get takes in an id and returns an instance of Snippet and a possible error
+// Get takes in an id and returns an instance of Snippet and a possible error. It doesn't check the snippet's
+// visibility; use Snippet.VisibleTo before showing it to a user.
 func (m *SnippetModel) Get(id int) (*Snippet, error) {
 	// Statement that will be executed
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-             WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	stmt := `SELECT ` + snippetColumns + `
+             WHERE s.expires > UTC_TIMESTAMP() AND s.id = ?`
 
 	// Use QueryRow() method on the connection pool to execute the statement, passing in the untrusted
 	// id variable as a value for the placeholder parameter. This returns a pointer to a sql.Row object
 	// which holds the result from the database.
 	row := m.DB.QueryRow(stmt, id)
 
-	// Initialize a pointer to a new zeroed Snippet struct
-	s := &Snippet{}
-
-	// Use row.Scan() to copy the values from each field in sql.row to the corresponding field in the Snippet
-	// struct. The arguments to row.Scan are *pointers* to the target for the copied data and the number of
-	// arguments must be exactly the same as the number of columns returned by the statement.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	// Use scanSnippet() to copy the values from each field in sql.row to the corresponding field in the Snippet
+	// struct.
+	s, err := scanSnippet(row)
 	if err != nil {
 		// If the query returns no rows, then row.Scan() will return a sql.ErrNoRows error. Use the errors.Is()
 		// function to check for that error specifically, and return a custom ErrNoRecord error.
@@ -94,20 +212,418 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 	return s, nil
 }
 
-// GetLatest returns a slice of instances of Snippet and a possible error
-func (m *SnippetModel) GetLatest() ([]*Snippet, error) {
+// Update replaces the title, content, language, visibility, expiry time and tags of the snippet with the given id,
+// recording a new revision made by the given user if the title or content changed. It returns ErrNoRecord if no live
+// snippet with that id exists.
+func (m *SnippetModel) Update(id int, title, content, language, visibility string, expires time.Time, tags []string,
+	userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	changed, err := contentChanged(tx, id, title, content)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE snippets SET title = ?, content = ?, language = ?, visibility = ?,
+             expires = ? WHERE id = ?`
+
+	_, err = tx.Exec(stmt, title, content, language, visibility, expires.UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		_, err = insertRevision(tx, id, title, content, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = setTags(tx, id, tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Extend moves the expiry time of the snippet with the given id to expires, which must be later than its current
+// expiry time. It returns ErrNoRecord if no live snippet with that id exists, or if it already expires at or after
+// the given time.
+func (m *SnippetModel) Extend(id int, expires time.Time) error {
+	stmt := `UPDATE snippets SET expires = ? WHERE id = ? AND expires > UTC_TIMESTAMP() AND expires < ?`
+
+	result, err := m.DB.Exec(stmt, expires.UTC(), id, expires.UTC())
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// UpdateContent replaces the title and content of the snippet with the given id without changing its expiry,
+// recording a new revision made by the given user if either changed. It is used when restoring an earlier revision.
+// It returns ErrNoRecord if no live snippet with that id exists.
+func (m *SnippetModel) UpdateContent(id int, title string, content string, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	changed, err := contentChanged(tx, id, title, content)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+
+	stmt := `UPDATE snippets SET title = ?, content = ? WHERE id = ?`
+
+	_, err = tx.Exec(stmt, title, content, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = insertRevision(tx, id, title, content, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// contentChanged reports whether title and content differ from those of the live snippet with the given id, so that
+// saving a snippet without changing it doesn't add a revision. It returns ErrNoRecord if no live snippet with that id
+// exists. MySQL reports zero affected rows when an UPDATE doesn't change anything, so this is also how the existence
+// of the snippet is checked, rather than with RowsAffected().
+func contentChanged(tx *Tx, id int, title, content string) (bool, error) {
+	var oldTitle, oldContent string
+
+	stmt := `SELECT title, content FROM snippets WHERE id = ? AND expires > UTC_TIMESTAMP()`
+
+	err := tx.QueryRow(stmt, id).Scan(&oldTitle, &oldContent)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrNoRecord
+		}
+		return false, err
+	}
+	return title != oldTitle || content != oldContent, nil
+}
+
+// Delete removes the snippet with the given id, along with its tags and revisions. It returns ErrNoRecord if no
+// snippet with that id exists.
+func (m *SnippetModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM snippets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	// Use the RowsAffected() method on the result to check whether anything was actually deleted.
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	for _, stmt := range []string{
+		`DELETE FROM snippet_tags WHERE snippet_id = ?`,
+		`DELETE FROM snippet_revisions WHERE snippet_id = ?`,
+	} {
+		_, err = tx.Exec(stmt, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Consume marks the burn after reading snippet with the given id as read, clears its content and deletes its revisions.
+// The check and the update are a single statement, so when several requests race to read a snippet exactly one of them
+// succeeds; the others get ErrAlreadyRead, as does every later call. It returns ErrNoRecord if no live burn after
+// reading snippet with that id exists.
+func (m *SnippetModel) Consume(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET read_at = UTC_TIMESTAMP(), content = ''
+             WHERE id = ? AND burn_after_reading = TRUE AND read_at IS NULL AND expires > UTC_TIMESTAMP()`
+
+	result, err := tx.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// Nothing was updated, so find out whether that's because the snippet has already been read.
+		var read bool
+		stmt = `SELECT read_at IS NOT NULL FROM snippets
+                WHERE id = ? AND burn_after_reading = TRUE AND expires > UTC_TIMESTAMP()`
+		err = tx.QueryRow(stmt, id).Scan(&read)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoRecord
+			}
+			return err
+		}
+		if read {
+			return ErrAlreadyRead
+		}
+		return ErrNoRecord
+	}
+
+	// The revisions hold copies of the content, so they have to go too.
+	_, err = tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeExpired permanently removes up to limit expired snippets, oldest expiry first, along with their tags and
+// revisions, and returns how many were removed. When archive is true each snippet is copied into the
+// snippets_archive table before it is removed; its tags and revisions aren't kept. Callers purge everything by
+// calling it until it returns fewer than limit.
+func (m *SnippetModel) PurgeExpired(limit int, archive bool) (int, error) {
+	rows, err := m.DB.Query(`SELECT id FROM snippets WHERE expires <= UTC_TIMESTAMP() ORDER BY expires LIMIT ?`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var args []any
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		args = append(args, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(args) == 0 {
+		return 0, nil
+	}
+
+	// Build one placeholder per ID, rather than interpolating the IDs into the statements.
+	in := `(` + strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ") + `)`
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if archive {
+		// The expiry is checked again in case a snippet was extended since it was selected.
+		stmt := `INSERT INTO snippets_archive (id, title, content, language, visibility, slug, burn_after_reading,
+                 read_at, created, expires, user_id, archived)
+                 SELECT id, title, content, language, visibility, slug, burn_after_reading, read_at, created, expires,
+                 user_id, UTC_TIMESTAMP() FROM snippets WHERE expires <= UTC_TIMESTAMP() AND id IN ` + in
+		_, err = tx.Exec(stmt, args...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM snippets WHERE expires <= UTC_TIMESTAMP() AND id IN `+in, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// Tags and revisions are only removed once their snippet has gone, so a snippet extended since it was selected
+	// keeps them.
+	for _, stmt := range []string{
+		`DELETE FROM snippet_tags WHERE snippet_id IN ` + in + ` AND snippet_id NOT IN (SELECT id FROM snippets)`,
+		`DELETE FROM snippet_revisions WHERE snippet_id IN ` + in + ` AND snippet_id NOT IN (SELECT id FROM snippets)`,
+	} {
+		_, err = tx.Exec(stmt, args...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// GetBySlug returns the live snippet with the given slug and a possible error. Like Get, it doesn't check the
+// snippet's visibility.
+func (m *SnippetModel) GetBySlug(slug string) (*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+             WHERE s.expires > UTC_TIMESTAMP() AND s.slug = ?`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// GetLatest returns one page of the newest live, public snippets, along with the pagination metadata and a
+// possible error. Pages are numbered from 1.
+func (m *SnippetModel) GetLatest(page, pageSize int) ([]*Snippet, Metadata, error) {
+	pageSize = ClampPageSize(pageSize)
+
+	total, err := m.count(`WHERE s.expires > UTC_TIMESTAMP()` + listedOnly)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
 	// Statement that will be executed
-	stmt := `SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP()
-             ORDER BY id DESC LIMIT 10`
+	stmt := `SELECT ` + snippetColumns + ` WHERE s.expires > UTC_TIMESTAMP()` + listedOnly + `
+             ORDER BY s.id DESC LIMIT ? OFFSET ?`
+
+	snippets, err := m.query(stmt, pageSize, offset(page, pageSize))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return snippets, CalculateMetadata(total, page, pageSize), nil
+}
+
+// GetLatestAfter returns up to limit live, public snippets, newest first, starting after the snippet with the given
+// id, or from the newest snippet if after is 0. Unlike GetLatest, this keyset pagination isn't thrown off by snippets
+// being created or deleted between requests. The bool result reports whether there are more snippets to fetch.
+func (m *SnippetModel) GetLatestAfter(after, limit int) ([]*Snippet, bool, error) {
+	limit = ClampPageSize(limit)
+
+	// Snippet IDs are positive, so the largest possible int makes every snippet come "after" the cursor.
+	if after < 1 {
+		after = math.MaxInt32
+	}
+
+	// One more snippet than was asked for is selected, to find out whether there's another page without a second
+	// query.
+	stmt := `SELECT ` + snippetColumns + ` WHERE s.expires > UTC_TIMESTAMP() AND s.id < ?` + listedOnly + `
+             ORDER BY s.id DESC LIMIT ?`
+
+	snippets, err := m.query(stmt, after, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(snippets) > limit {
+		return snippets[:limit], true, nil
+	}
+	return snippets, false, nil
+}
+
+// CountLive returns the total number of live, public snippets.
+func (m *SnippetModel) CountLive() (int, error) {
+	return m.count(`WHERE s.expires > UTC_TIMESTAMP()` + listedOnly)
+}
+
+// GetByUser returns one page of the given user's live snippets, newest first and whatever their visibility, along
+// with the pagination metadata and a possible error.
+func (m *SnippetModel) GetByUser(userID, page, pageSize int) ([]*Snippet, Metadata, error) {
+	pageSize = ClampPageSize(pageSize)
+
+	total, err := m.count(`WHERE s.expires > UTC_TIMESTAMP() AND s.user_id = ?`, userID)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` WHERE s.expires > UTC_TIMESTAMP() AND s.user_id = ?
+             ORDER BY s.id DESC LIMIT ? OFFSET ?`
+
+	snippets, err := m.query(stmt, userID, pageSize, offset(page, pageSize))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return snippets, CalculateMetadata(total, page, pageSize), nil
+}
+
+// GetByTag returns one page of the live, public snippets with the given tag, newest first, along with the pagination
+// metadata and a possible error.
+func (m *SnippetModel) GetByTag(tag string, page, pageSize int) ([]*Snippet, Metadata, error) {
+	pageSize = ClampPageSize(pageSize)
+
+	where := `WHERE s.expires > UTC_TIMESTAMP() AND EXISTS (SELECT 1 FROM snippet_tags st
+             INNER JOIN tags t ON t.id = st.tag_id WHERE st.snippet_id = s.id AND t.name = ?)` + listedOnly
 
+	total, err := m.count(where, tag)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` ` + where + ` ORDER BY s.id DESC LIMIT ? OFFSET ?`
+
+	snippets, err := m.query(stmt, tag, pageSize, offset(page, pageSize))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return snippets, CalculateMetadata(total, page, pageSize), nil
+}
+
+// count returns the number of snippets matching the given WHERE clause, which may refer to the snippets table as s.
+func (m *SnippetModel) count(where string, args ...any) (int, error) {
+	var total int
+
+	stmt := `SELECT COUNT(*) FROM snippets s ` + where
+
+	err := m.DB.QueryRow(stmt, args...).Scan(&total)
+	return total, err
+}
+
+// query executes a statement which selects snippetColumns and returns the resulting slice of instances of Snippet
+// and a possible error
+func (m *SnippetModel) query(stmt string, args ...any) ([]*Snippet, error) {
 	// Use the Query() method on the connection pool to execute the statement.
 	// This returns a sql.Rows result set.
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	// Defer rows.Close() to ensure the sql.Rows() result set is always properly closed before the Latest()
+	// Defer rows.Close() to ensure the sql.Rows() result set is always properly closed before the query()
 	// method returns. The defer statement should come after checking for an error from the Query() method,
 	// otherwise if Query() returns an error, the app will panic trying to close a nil result set.
 	defer func(rows *sql.Rows) {
@@ -116,27 +632,18 @@ func (m *SnippetModel) GetLatest() ([]*Snippet, error) {
 
 	// Initialize an empty slice to hold the Snippet structs
 	var snippets []*Snippet
-	// fmt.Println("Snippets := []*Snippet{} ", snippets) // For testing
 
 	// Use rows.Next() to iterate through the rows in the result set. This prepares the first (and then each
 	// subsequent) row to be acted on by the rows.Scan() method. If iteration over all the rows completes, then
 	// the result set automatically closes itself and frees up the underlying database connection.
 	for rows.Next() {
-		// Create a pointer to a now zeroed Snippet struct
-		s := &Snippet{}
-		// fmt.Println("s := &Snippet{} ", s) // For testing
-		// Use rows.Scan() to copy the values from each field in the row to the new Snippet object that
-		// has been created. Again, the arguments to row.Scan() must be pointers to the target to which to
-		// copy the data into, and the number of arguments must be exactly the same as the number of columns
-		// returned by the sql statement.
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		// Use scanSnippet() to copy the values from each field in the row to a new Snippet object.
+		s, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
-		// fmt.Println("s := &Snippet{} after scan -> ", s) // For testing
 		// Append the object to the slice of snippets
 		snippets = append(snippets, s)
-
 	}
 
 	// When the rows.Next() loop has finished, call rows.Err() to retrieve any error that was encountered
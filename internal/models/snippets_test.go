@@ -0,0 +1,443 @@
+package models_test
+
+import (
+	"errors"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"github.com/rlr524/snippetboxv2/internal/models/dbtest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSnippetModel(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		m := &models.SnippetModel{DB: db}
+		userID := insertUser(t, db, "Alice", "alice@example.com")
+
+		week := time.Now().AddDate(0, 0, 7)
+
+		public, err := m.Insert("Public", "An old silent pond", "", models.VisibilityPublic, week, userID, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unlisted, err := m.Insert("Unlisted", "A frog jumps into the pond", "", models.VisibilityUnlisted, week, userID,
+			false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		never, err := m.Insert("Never", "Splash! Silence again.", "", models.VisibilityPublic, models.NeverExpires, userID,
+			false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := m.Get(public)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Title != "Public" || s.CreatedBy.Name != "Alice" || !s.Expires.Equal(week.UTC().Truncate(time.Second)) {
+			t.Errorf("got %+v", s)
+		}
+
+		s, err = m.Get(never)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !s.NeverExpires() {
+			t.Errorf("got expiry %s; want never", s.Expires)
+		}
+
+		// Unlisted snippets can be found by slug but aren't listed.
+		s, err = m.Get(unlisted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.GetBySlug(s.Slug)
+		if err != nil {
+			t.Errorf("GetBySlug: %v", err)
+		}
+
+		latest, metadata, err := m.GetLatest(1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(latest) != 2 || latest[0].ID != never || latest[1].ID != public || metadata.TotalRecords != 2 {
+			t.Errorf("GetLatest: got %d snippets, %d total records", len(latest), metadata.TotalRecords)
+		}
+
+		mine, _, err := m.GetByUser(userID, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mine) != 3 {
+			t.Errorf("GetByUser: got %d snippets; want 3", len(mine))
+		}
+
+		// Extending only works if the new expiry is later.
+		err = m.Extend(public, week.AddDate(0, 0, -1))
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("shortening the expiry: got %v; want %v", err, models.ErrNoRecord)
+		}
+		err = m.Extend(public, week.AddDate(0, 0, 1))
+		if err != nil {
+			t.Errorf("extending the expiry: %v", err)
+		}
+
+		err = m.Delete(public)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Get(public)
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("getting a deleted snippet: got %v; want %v", err, models.ErrNoRecord)
+		}
+	})
+}
+
+func TestSnippetModelExpiry(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		m := &models.SnippetModel{DB: db}
+		userID := insertUser(t, db, "Alice", "alice@example.com")
+
+		// Times are stored to the second, so the boundaries are a couple of seconds either side of now.
+		now := time.Now()
+		expired, err := m.Insert("Expired", "Gone", "", models.VisibilityPublic, now.Add(-2*time.Second), userID,
+			false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		live, err := m.Insert("Live", "Still here", "", models.VisibilityPublic, now.Add(5*time.Second), userID,
+			false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = m.Get(expired)
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("getting an expired snippet: got %v; want %v", err, models.ErrNoRecord)
+		}
+		_, err = m.Get(live)
+		if err != nil {
+			t.Errorf("getting a snippet about to expire: %v", err)
+		}
+
+		latest, metadata, err := m.GetLatest(1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(latest) != 1 || latest[0].ID != live || metadata.TotalRecords != 1 {
+			t.Errorf("GetLatest: got %d snippets, %d total records; want only the live one", len(latest),
+				metadata.TotalRecords)
+		}
+
+		count, err := m.CountLive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("CountLive: got %d; want 1", count)
+		}
+	})
+}
+
+func TestSnippetModelGetLatestPages(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		m := &models.SnippetModel{DB: db}
+		userID := insertUser(t, db, "Alice", "alice@example.com")
+
+		week := time.Now().AddDate(0, 0, 7)
+		var ids []int
+		for _, title := range []string{"First", "Second", "Third"} {
+			id, err := m.Insert(title, "An old silent pond", "", models.VisibilityPublic, week, userID, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+
+		tests := []struct {
+			page int
+			want []int
+		}{
+			{1, []int{ids[2], ids[1]}},
+			{2, []int{ids[0]}},
+			{3, nil},
+		}
+		for _, tt := range tests {
+			snippets, metadata, err := m.GetLatest(tt.page, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []int
+			for _, s := range snippets {
+				got = append(got, s.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("page %d: got snippets %v; want %v", tt.page, got, tt.want)
+			}
+			if metadata.LastPage != 2 || metadata.TotalRecords != 3 {
+				t.Errorf("page %d: got %+v; want 2 pages of 3 records", tt.page, metadata)
+			}
+		}
+	})
+}
+
+func TestSnippetModelConsume(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		m := &models.SnippetModel{DB: db}
+		userID := insertUser(t, db, "Alice", "alice@example.com")
+
+		id, err := m.Insert("Secret", "Burn me", "", models.VisibilityUnlisted, time.Now().Add(time.Hour), userID, true, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = m.Consume(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.Consume(id)
+		if !errors.Is(err, models.ErrAlreadyRead) {
+			t.Errorf("consuming twice: got %v; want %v", err, models.ErrAlreadyRead)
+		}
+
+		s, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Content != "" || !s.ReadAt.Valid {
+			t.Errorf("got content %q and read at %v; want the content cleared", s.Content, s.ReadAt)
+		}
+
+		revisions, err := (&models.SnippetRevisionModel{DB: db}).GetAll(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(revisions) != 0 {
+			t.Errorf("got %d revisions; want 0", len(revisions))
+		}
+	})
+}
+
+func TestSnippetModelRevisions(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		m := &models.SnippetModel{DB: db}
+		revisions := &models.SnippetRevisionModel{DB: db}
+		tags := &models.TagModel{DB: db}
+		userID := insertUser(t, db, "Alice", "alice@example.com")
+
+		week := time.Now().AddDate(0, 0, 7)
+		id, err := m.Insert("Haiku", "An old silent pond", "", models.VisibilityPublic, week, userID, false,
+			[]string{"poetry"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// checkRevisions checks the number of revisions and the snippet's tags.
+		checkRevisions := func(step string, want int, wantTags []string) {
+			t.Helper()
+			all, err := revisions.GetAll(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(all) != want {
+				t.Errorf("%s: got %d revisions; want %d", step, len(all), want)
+			}
+			got, err := tags.GetForSnippets([]int{id})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got[id], wantTags) {
+				t.Errorf("%s: got tags %v; want %v", step, got[id], wantTags)
+			}
+		}
+
+		checkRevisions("creating", 1, []string{"poetry"})
+
+		// Changing only the tags and expiry doesn't record a revision.
+		err = m.Update(id, "Haiku", "An old silent pond", "", models.VisibilityPublic, week.AddDate(0, 0, 1),
+			[]string{"japan", "poetry"}, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkRevisions("updating the tags", 1, []string{"japan", "poetry"})
+
+		err = m.Update(id, "Haiku", "A frog jumps into the pond", "", models.VisibilityPublic, week, nil, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkRevisions("updating the content", 2, nil)
+
+		err = m.UpdateContent(id, "Haiku", "An old silent pond", userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.UpdateContent(id, "Haiku", "An old silent pond", userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkRevisions("restoring twice", 3, nil)
+
+		err = m.Update(id+1, "Haiku", "An old silent pond", "", models.VisibilityPublic, week, nil, userID)
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("updating a missing snippet: got %v; want %v", err, models.ErrNoRecord)
+		}
+		err = m.UpdateContent(id+1, "Haiku", "An old silent pond", userID)
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("restoring a missing snippet: got %v; want %v", err, models.ErrNoRecord)
+		}
+	})
+}
+
+func TestSnippetModelDelete(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		m := &models.SnippetModel{DB: db}
+		userID := insertUser(t, db, "Alice", "alice@example.com")
+
+		week := time.Now().AddDate(0, 0, 7)
+		deleted, err := m.Insert("Haiku", "An old silent pond", "", models.VisibilityPublic, week, userID, false,
+			[]string{"poetry"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept, err := m.Insert("Limerick", "There once was a frog", "", models.VisibilityPublic, week, userID, false,
+			[]string{"poetry"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.UpdateContent(deleted, "Haiku", "A frog jumps into the pond", userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = m.Delete(deleted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.Delete(deleted)
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("deleting twice: got %v; want %v", err, models.ErrNoRecord)
+		}
+
+		// count returns the number of rows in table belonging to the given snippet.
+		count := func(table string, snippetID int) int {
+			t.Helper()
+			var n int
+			err := db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE snippet_id = ?`, snippetID).Scan(&n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return n
+		}
+
+		for _, table := range []string{"snippet_revisions", "snippet_tags"} {
+			if n := count(table, deleted); n != 0 {
+				t.Errorf("got %d rows in %s for the deleted snippet; want 0", n, table)
+			}
+			if n := count(table, kept); n != 1 {
+				t.Errorf("got %d rows in %s for the other snippet; want 1", n, table)
+			}
+		}
+	})
+}
+
+func TestSnippetModelPurgeExpired(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		m := &models.SnippetModel{DB: db}
+		userID := insertUser(t, db, "Alice", "alice@example.com")
+
+		live, err := m.Insert("Live", "Still here", "", models.VisibilityPublic, time.Now().Add(time.Hour), userID, false,
+			nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range 3 {
+			_, err = m.Insert("Expired", "Gone", "", models.VisibilityPublic, time.Now().Add(-time.Hour), userID, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		n, err := m.PurgeExpired(2, true)
+		if err != nil || n != 2 {
+			t.Fatalf("first batch: got %d, %v; want 2", n, err)
+		}
+		n, err = m.PurgeExpired(2, true)
+		if err != nil || n != 1 {
+			t.Fatalf("second batch: got %d, %v; want 1", n, err)
+		}
+
+		var archived int
+		err = db.QueryRow(`SELECT COUNT(*) FROM snippets_archive`).Scan(&archived)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if archived != 3 {
+			t.Errorf("got %d archived snippets; want 3", archived)
+		}
+
+		_, err = m.Get(live)
+		if err != nil {
+			t.Errorf("getting the live snippet: %v", err)
+		}
+	})
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		m := &models.SnippetModel{DB: db, FullText: db.Dialect == models.MySQL}
+		tags := &models.TagModel{DB: db}
+		userID := insertUser(t, db, "Alice", "alice@example.com")
+
+		week := time.Now().AddDate(0, 0, 7)
+		_, err := m.Insert("Haiku", "An old silent pond", "", models.VisibilityPublic, week, userID, false,
+			[]string{"poetry", "japan"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Insert("Limerick", "There once was a 100% frog", "", models.VisibilityPublic, week, userID, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name    string
+			filters models.SearchFilters
+			want    int
+		}{
+			{"Word", models.SearchFilters{Query: "POND"}, 1},
+			{"Phrase", models.SearchFilters{Query: `"silent pond"`}, 1},
+			{"Wildcard characters are literal", models.SearchFilters{Query: "100%"}, 1},
+			{"Author", models.SearchFilters{Author: "Alice"}, 2},
+			{"Tag", models.SearchFilters{Tag: "poetry"}, 1},
+			{"No match", models.SearchFilters{Query: "toad"}, 0},
+			// Short words and stopwords aren't in MySQL's full-text index, so they're matched with LIKE there.
+			{"Two letters", models.SearchFilters{Query: "an"}, 1},
+			{"Two letters and a word", models.SearchFilters{Query: "an pond"}, 1},
+			{"Stopword and a word", models.SearchFilters{Query: "was frog"}, 1},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				tt.filters.Page, tt.filters.PageSize = 1, 10
+				snippets, metadata, err := m.Search(tt.filters)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(snippets) != tt.want || metadata.TotalRecords != tt.want {
+					t.Errorf("got %d snippets, %d total records; want %d", len(snippets), metadata.TotalRecords,
+						tt.want)
+				}
+			})
+		}
+
+		all, err := tags.GetAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 2 || all[0].Name != "japan" || all[0].Count != 1 {
+			t.Errorf("GetAll: got %d tags", len(all))
+		}
+	})
+}
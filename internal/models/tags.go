@@ -0,0 +1,149 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Tag is a label which can be attached to any number of snippets. Count is the number of live snippets with the
+// tag, and is only set by TagModel.GetAll.
+type Tag struct {
+	ID    int
+	Name  string
+	Count int
+}
+
+type TagModel struct {
+	DB *DB
+}
+
+// setTags replaces the tags on the given snippet with the given tag names in tx, creating any tags which don't exist
+// yet. The names should already be normalized and validated. Tags are only set alongside the change to the snippet
+// they belong to, by SnippetModel, so a snippet is never seen with only some of its tags.
+func setTags(tx *Tx, snippetID int, names []string) error {
+	_, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, snippetID)
+	if err != nil {
+		return err
+	}
+
+	// Both statements leave an existing tag with the same name (the name column is UNIQUE) in place. MySQL doesn't
+	// support ON CONFLICT.
+	insertTag := `INSERT INTO tags (name) VALUES (?) ON CONFLICT DO NOTHING`
+	if tx.dialect == MySQL {
+		insertTag = `INSERT IGNORE INTO tags (name) VALUES (?)`
+	}
+
+	for _, name := range names {
+		_, err = tx.Exec(insertTag, name)
+		if err != nil {
+			return err
+		}
+
+		// The snippet ID is selected from the snippets table, rather than being a placeholder in the select list, as
+		// Postgres can't work out the type of a placeholder there.
+		_, err = tx.Exec(`INSERT INTO snippet_tags (snippet_id, tag_id) SELECT s.id, t.id FROM snippets s, tags t
+                          WHERE s.id = ? AND t.name = ?`, snippetID, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetForSnippets returns the tag names of each of the given snippets, keyed by snippet ID. Snippets without tags
+// have no entry in the map.
+func (m *TagModel) GetForSnippets(snippetIDs []int) (map[int][]string, error) {
+	tags := make(map[int][]string)
+	if len(snippetIDs) == 0 {
+		return tags, nil
+	}
+
+	// Build one placeholder per ID, rather than interpolating the IDs into the statement.
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(snippetIDs)), ", ")
+	args := make([]any, len(snippetIDs))
+	for i, id := range snippetIDs {
+		args[i] = id
+	}
+
+	stmt := `SELECT st.snippet_id, t.name FROM snippet_tags st INNER JOIN tags t ON t.id = st.tag_id
+             WHERE st.snippet_id IN (` + placeholders + `) ORDER BY t.name`
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		var id int
+		var name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return nil, err
+		}
+		tags[id] = append(tags[id], name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// Attach fills in the Tags field of each of the given snippets.
+func (m *TagModel) Attach(snippets ...*Snippet) error {
+	ids := make([]int, len(snippets))
+	for i, s := range snippets {
+		ids[i] = s.ID
+	}
+
+	tags, err := m.GetForSnippets(ids)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range snippets {
+		s.Tags = tags[s.ID]
+	}
+	return nil
+}
+
+// GetAll returns every tag which is on at least one listed snippet, with the number of listed snippets it is on, in
+// alphabetical order. Listed snippets are live, public and not burn after reading.
+func (m *TagModel) GetAll() ([]*Tag, error) {
+	stmt := `SELECT t.id, t.name, COUNT(*) FROM tags t
+             INNER JOIN snippet_tags st ON st.tag_id = t.id
+             INNER JOIN snippets s ON s.id = st.snippet_id
+             WHERE s.expires > UTC_TIMESTAMP() AND s.visibility = 'public' AND s.burn_after_reading = FALSE
+             GROUP BY t.id, t.name ORDER BY t.name`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var tags []*Tag
+
+	for rows.Next() {
+		t := &Tag{}
+		err = rows.Scan(&t.ID, &t.Name, &t.Count)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,22 @@
+package models_test
+
+import (
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"testing"
+)
+
+// insertUser adds a user to the database and returns its ID.
+func insertUser(t *testing.T, db *models.DB, name, email string) int {
+	t.Helper()
+
+	m := &models.UserModel{DB: db}
+	err := m.Insert(name, email, "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := m.Authenticate(email, "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// The scopes which can be granted to an API token. A read-only token can only be used with safe (GET) API routes.
+const (
+	ScopeRead      = "read"
+	ScopeReadWrite = "read-write"
+)
+
+// tokenPrefix is added to the start of every plain-text token so that leaked tokens are easy to recognise, for
+// example by secret scanners.
+const tokenPrefix = "sbx_"
+
+// Token is a personal API token. Only a SHA-256 hash of the token is stored; the plain-text token is returned once
+// when it is created and can't be recovered afterwards.
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string
+	Scope    string
+	Created  time.Time
+	Expires  sql.NullTime
+	LastUsed sql.NullTime
+}
+
+// CanWrite reports whether the token may be used for requests which change data.
+func (t *Token) CanWrite() bool {
+	return t.Scope == ScopeReadWrite
+}
+
+type TokenModel struct {
+	DB *DB
+}
+
+// hashToken returns the hex encoded SHA-256 hash of a plain-text token. Unlike passwords, tokens are long random
+// values, so a fast hash is enough and bcrypt isn't needed.
+func hashToken(plaintext string) string {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(hash[:])
+}
+
+// Insert creates a new token for the given user, and returns the plain-text token and the new token's ID. If
+// expires is 0 the token never expires, otherwise it expires after the given number of days.
+func (m *TokenModel) Insert(userID int, name string, scope string, expires int) (string, int, error) {
+	// 20 random bytes are encoded as 32 base32 characters without padding.
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", 0, err
+	}
+	plaintext := tokenPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+
+	stmt := `INSERT INTO tokens (user_id, name, hash, scope, created, expires) VALUES (?, ?, ?, ?, UTC_TIMESTAMP(),
+             CASE WHEN ? = 0 THEN NULL ELSE DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY) END)`
+
+	id, err := m.DB.insert(stmt, userID, name, hashToken(plaintext), scope, expires, expires)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return plaintext, id, nil
+}
+
+// Authenticate returns the token matching the given plain-text token, and records that it has just been used. If
+// no such token exists, or it has expired, ErrInvalidToken is returned.
+func (m *TokenModel) Authenticate(plaintext string) (*Token, error) {
+	t := &Token{}
+
+	stmt := `SELECT id, user_id, name, scope, created, expires, last_used FROM tokens
+             WHERE hash = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP())`
+
+	err := m.DB.QueryRow(stmt, hashToken(plaintext)).Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created,
+		&t.Expires, &t.LastUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		} else {
+			return nil, err
+		}
+	}
+
+	stmt = `UPDATE tokens SET last_used = UTC_TIMESTAMP() WHERE id = ?`
+
+	_, err = m.DB.Exec(stmt, t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// GetAllForUser returns all of the given user's tokens, including expired ones, newest first.
+func (m *TokenModel) GetAllForUser(userID int) ([]*Token, error) {
+	stmt := `SELECT id, user_id, name, scope, created, expires, last_used FROM tokens
+             WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var tokens []*Token
+
+	for rows.Next() {
+		t := &Token{}
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &t.Expires, &t.LastUsed)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete revokes the token with the given ID, so long as it belongs to the given user. It returns ErrNoRecord if
+// the user has no such token.
+func (m *TokenModel) Delete(id int, userID int) error {
+	stmt := `DELETE FROM tokens WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
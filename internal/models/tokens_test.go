@@ -0,0 +1,57 @@
+package models_test
+
+import (
+	"errors"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"github.com/rlr524/snippetboxv2/internal/models/dbtest"
+	"testing"
+)
+
+func TestTokenModel(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		m := &models.TokenModel{DB: db}
+		userID := insertUser(t, db, "Alice", "alice@example.com")
+
+		plaintext, id, err := m.Insert(userID, "CLI", models.ScopeRead, 30)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := m.Authenticate(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.ID != id || token.UserID != userID || token.CanWrite() || !token.Expires.Valid {
+			t.Errorf("got %+v", token)
+		}
+		if days := token.Expires.Time.Sub(token.Created).Hours() / 24; days != 30 {
+			t.Errorf("got a token which expires after %.1f days; want 30", days)
+		}
+
+		_, err = m.Authenticate(plaintext + "x")
+		if !errors.Is(err, models.ErrInvalidToken) {
+			t.Errorf("authenticating an unknown token: got %v; want %v", err, models.ErrInvalidToken)
+		}
+
+		tokens, err := m.GetAllForUser(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tokens) != 1 || !tokens[0].LastUsed.Valid {
+			t.Errorf("got %d tokens", len(tokens))
+		}
+
+		err = m.Delete(id, userID+1)
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("deleting another user's token: got %v; want %v", err, models.ErrNoRecord)
+		}
+		err = m.Delete(id, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Authenticate(plaintext)
+		if !errors.Is(err, models.ErrInvalidToken) {
+			t.Errorf("authenticating a revoked token: got %v; want %v", err, models.ErrInvalidToken)
+		}
+	})
+}
@@ -3,9 +3,7 @@ package models
 import (
 	"database/sql"
 	"errors"
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
-	"strings"
 	"time"
 )
 
@@ -19,7 +17,7 @@ type User struct {
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB *DB
 }
 
 // Insert adds a new record to the "users" table.
@@ -34,8 +32,8 @@ func (m *UserModel) Insert(name, email, password string) error {
 
 	// Use the Exec() method to insert the user details and hashed password into the users table.
 	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
-	// Why not create a method to check the db for the email vs depending on the MySQL error number, which
-	// MySQL could change and tightly couples this method to MySQL? Because that method introduces a race
+	// Why not create a method to check the db for the email vs depending on each driver's error for a duplicate
+	// key, which ties this method to the databases it knows about? Because that method introduces a race
 	// condition to the application. If two users try to sign up with the same email at exactly the same time,
 	// both submissions will pass the validation check but only one INSERT statement will succeed and the other
 	// will violate the UNIQUE constraint set on email in the database and get a 500 error. Neither case is
@@ -43,15 +41,10 @@ func (m *UserModel) Insert(name, email, password string) error {
 	// good reason why we should be using an ORM instead of rolling our own SQL.
 	// TODO: At some point, determine how to optimize this. Use an ORM?
 	if err != nil {
-		// If this returns an error, we use the errors.As() function to check whether the error has the type
-		// *mysql.MySQLError. If it does, the error will be assigned to the mySQLError variable. We can then check
-		// whether the error relates to our users_uc_email key by checking if the error code equals 1062 and
-		// the contents of the error message string. If it does, we return an ErrDuplicateEmail error.
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
+		// If this returns an error, check whether it is a violation of the UNIQUE constraint on email. MySQL names
+		// the users_uc_email key in the error message, and SQLite names the users.email column.
+		if isDuplicate(err, "users_uc_email", "users.email") {
+			return ErrDuplicateEmail
 		}
 		return err
 	}
@@ -59,16 +52,16 @@ func (m *UserModel) Insert(name, email, password string) error {
 }
 
 // Authenticate verifies whether a user exists with the provided email address and password and
-// returns the relevant user ID if they do.
+// returns the relevant user ID if they do. If the user has been deactivated, it returns ErrInactiveUser.
 func (m *UserModel) Authenticate(email, password string) (int, error) {
 	// Retrieve the id and hashed password associated with the given email.
 	// If no matching email exists, we return the ErrInvalidCredentials error.
-	var id int
+	var id, active int
 	var hashedPassword []byte
 
-	stmt := "SELECT id, hashed_password FROM users WHERE email = ?"
+	stmt := "SELECT id, hashed_password, active FROM users WHERE email = ?"
 
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword, &active)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -87,10 +80,39 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 			return 0, err
 		}
 	}
+
+	// Only tell the user that their account has been deactivated once they have proved it's theirs.
+	if active != 1 {
+		return 0, ErrInactiveUser
+	}
 	return id, nil
 }
 
-// Exists checks if a user exists given a specific ID.
+// Exists checks if an active user exists given a specific ID.
 func (m *UserModel) Exists(id int) (bool, error) {
-	return false, nil
+	var exists bool
+
+	// The EXISTS() subquery returns a single boolean value, so there is never a sql.ErrNoRows error to handle.
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ? AND active = 1)"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	return exists, err
+}
+
+// Get returns the user with the given ID, or ErrNoRecord if no such user exists. The hashed password is
+// deliberately not selected, as the returned user is passed around the application and into the templates.
+func (m *UserModel) Get(id int) (*User, error) {
+	u := &User{}
+
+	stmt := "SELECT id, name, email, created, active FROM users WHERE id = ?"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return u, nil
 }
@@ -0,0 +1,99 @@
+package models_test
+
+import (
+	"errors"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"github.com/rlr524/snippetboxv2/internal/models/dbtest"
+	"strings"
+	"testing"
+)
+
+func TestUserModel(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, db *models.DB) {
+		m := &models.UserModel{DB: db}
+
+		id := insertUser(t, db, "Alice", "alice@example.com")
+
+		err := m.Insert("Another Alice", "alice@example.com", "pa$$word")
+		if !errors.Is(err, models.ErrDuplicateEmail) {
+			t.Errorf("inserting a duplicate email: got %v; want %v", err, models.ErrDuplicateEmail)
+		}
+
+		// A password which doesn't match the bcrypt hash, a differently cased password and an unknown email address
+		// are all invalid credentials.
+		for _, tt := range []struct {
+			email    string
+			password string
+		}{
+			{"alice@example.com", "wrong password"},
+			{"alice@example.com", "PA$$WORD"},
+			{"bob@example.com", "pa$$word"},
+		} {
+			_, err = m.Authenticate(tt.email, tt.password)
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				t.Errorf("authenticating as %s with %q: got %v; want %v", tt.email, tt.password, err,
+					models.ErrInvalidCredentials)
+			}
+		}
+
+		// The password is stored as a bcrypt hash, not as plain text.
+		var hashedPassword string
+		err = db.QueryRow(`SELECT hashed_password FROM users WHERE id = ?`, id).Scan(&hashedPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(hashedPassword, "$2a$12$") {
+			t.Errorf("got stored password %q; want a bcrypt hash with cost 12", hashedPassword)
+		}
+
+		u, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Name != "Alice" || u.Email != "alice@example.com" || u.Active != 1 || u.Created.IsZero() {
+			t.Errorf("got %+v", u)
+		}
+
+		for _, tt := range []struct {
+			id   int
+			want bool
+		}{{id, true}, {id + 1, false}} {
+			exists, err := m.Exists(tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists != tt.want {
+				t.Errorf("Exists(%d): got %t; want %t", tt.id, exists, tt.want)
+			}
+		}
+
+		_, err = m.Get(id + 1)
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("getting an unknown user: got %v; want %v", err, models.ErrNoRecord)
+		}
+
+		// A deactivated user no longer exists as far as the session checks are concerned.
+		_, err = db.Exec(`UPDATE users SET active = 0 WHERE id = ?`, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exists, err := m.Exists(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists {
+			t.Error("Exists: got true for a deactivated user; want false")
+		}
+
+		// A deactivated user can't log in, even with the right password.
+		_, err = m.Authenticate("alice@example.com", "pa$$word")
+		if !errors.Is(err, models.ErrInactiveUser) {
+			t.Errorf("authenticating a deactivated user: got %v; want %v", err, models.ErrInactiveUser)
+		}
+		_, err = m.Authenticate("alice@example.com", "wrong password")
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			t.Errorf("authenticating a deactivated user with the wrong password: got %v; want %v", err,
+				models.ErrInvalidCredentials)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package seed
+
+import (
+	"fmt"
+	"github.com/rlr524/snippetboxv2/internal/expiry"
+	"github.com/rlr524/snippetboxv2/internal/models"
+)
+
+// DemoPassword is the password of every demo user.
+const DemoPassword = "pa$$word"
+
+// demoUsers are the users who own the demo snippets.
+var demoUsers = []User{
+	{"Alice Jones", "alice@example.com", DemoPassword},
+	{"Bob Smith", "bob@example.com", DemoPassword},
+	{"Carol Lee", "carol@example.com", DemoPassword},
+}
+
+// demoSnippets are the snippets the demo data is made from, in a range of languages.
+var demoSnippets = []Snippet{
+	{
+		Title:    "Reverse a string",
+		Language: "go",
+		Tags:     []string{"go", "strings"},
+		Content: `func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}`,
+	},
+	{
+		Title:    "Read a file line by line",
+		Language: "python",
+		Tags:     []string{"python", "files"},
+		Content: `with open("notes.txt") as f:
+    for number, line in enumerate(f, start=1):
+        print(number, line.rstrip())`,
+	},
+	{
+		Title:    "Debounce a function",
+		Language: "javascript",
+		Tags:     []string{"javascript", "events"},
+		Content: `function debounce(fn, wait) {
+  let timer;
+  return (...args) => {
+    clearTimeout(timer);
+    timer = setTimeout(() => fn(...args), wait);
+  };
+}`,
+	},
+	{
+		Title:    "Find the largest files",
+		Language: "bash",
+		Tags:     []string{"bash", "disk"},
+		Content:  `du -ah . 2>/dev/null | sort -rh | head -n 20`,
+	},
+	{
+		Title:    "Top customers by revenue",
+		Language: "sql",
+		Tags:     []string{"sql", "reporting"},
+		Content: `SELECT c.name, SUM(o.total) AS revenue
+FROM customers c
+JOIN orders o ON o.customer_id = c.id
+GROUP BY c.name
+ORDER BY revenue DESC
+LIMIT 10;`,
+	},
+	{
+		Title:    "Centre anything",
+		Language: "css",
+		Tags:     []string{"css", "layout"},
+		Content: `.centre {
+  display: grid;
+  place-items: center;
+  min-height: 100vh;
+}`,
+	},
+	{
+		Title:    "Minimal Dockerfile for a Go service",
+		Language: "docker",
+		Tags:     []string{"docker", "go"},
+		Content: `FROM golang:1.22 AS build
+WORKDIR /src
+COPY . .
+RUN CGO_ENABLED=0 go build -o /app ./cmd/web
+
+FROM gcr.io/distroless/static
+COPY --from=build /app /app
+ENTRYPOINT ["/app"]`,
+	},
+	{
+		Title:    "Sum a list with fold",
+		Language: "rust",
+		Tags:     []string{"rust", "iterators"},
+		Content: `fn main() {
+    let total: i32 = vec![1, 2, 3, 4].iter().fold(0, |acc, x| acc + x);
+    println!("{}", total);
+}`,
+	},
+	{
+		Title:    "Service health check response",
+		Language: "json",
+		Tags:     []string{"json", "api"},
+		Content: `{
+  "status": "ok",
+  "checks": {
+    "database": "ok",
+    "cache": "degraded"
+  }
+}`,
+	},
+	{
+		Title:      "Shopping list",
+		Visibility: models.VisibilityPrivate,
+		Content: `Eggs
+Flour
+Milk
+Coffee`,
+	},
+	{
+		Title:            "Wi-Fi password for the office",
+		Visibility:       models.VisibilityUnlisted,
+		BurnAfterReading: true,
+		Expires:          "1d",
+		Content:          `correct-horse-battery-staple`,
+	},
+	{
+		Title:    "An old silent pond",
+		Language: "markdown",
+		Tags:     []string{"poetry"},
+		Content: `An old silent pond...
+A frog jumps into the pond,
+splash! Silence again.
+
+– Matsuo Bashō`,
+	},
+}
+
+// demoVisibilities and demoLifetimes are cycled through for the demo snippets which don't set their own, so that the
+// demo data has a mix of each.
+var (
+	demoVisibilities = []string{models.VisibilityPublic, models.VisibilityPublic, models.VisibilityPublic,
+		models.VisibilityUnlisted, models.VisibilityPublic, models.VisibilityPrivate}
+	demoLifetimes = []string{"1y", expiry.Never, "30d", "1w", "1y", "1d"}
+)
+
+// Demo returns the demo users and n snippets shared between them. The same n always gives the same snippets, so
+// loading Demo(n) again only replaces snippets which have expired or been burnt, and loading a larger n adds the
+// extra snippets. Once the list of demo snippets runs out it is repeated, with a number added to the titles.
+func Demo(n int) Fixtures {
+	f := Fixtures{Users: demoUsers}
+
+	for i := range max(n, 0) {
+		s := demoSnippets[i%len(demoSnippets)]
+		if round := i / len(demoSnippets); round > 0 {
+			s.Title = fmt.Sprintf("%s (%d)", s.Title, round+1)
+		}
+		s.Owner = demoUsers[i%len(demoUsers)].Email
+		if s.Visibility == "" {
+			s.Visibility = demoVisibilities[i%len(demoVisibilities)]
+		}
+		if s.Expires == "" {
+			s.Expires = demoLifetimes[i%len(demoLifetimes)]
+		}
+		f.Snippets = append(f.Snippets, s)
+	}
+
+	return f
+}
@@ -0,0 +1,184 @@
+// Package seed loads users and snippets into the repositories, either the demo data used by cmd/seed to fill a new
+// development database, or fixtures written for tests. Loading is idempotent: users are matched by email address
+// and snippets by owner and title, and only the missing ones are created.
+package seed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rlr524/snippetboxv2/internal/expiry"
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"github.com/rlr524/snippetboxv2/internal/validator"
+	"io"
+	"time"
+)
+
+// Repositories are the stores the fixtures are loaded into. They can be the database models or the in-memory mocks.
+// Snippets are saved along with their first revision and tags, so there is no need for the revision and tag stores.
+type Repositories struct {
+	Users    models.UserRepository
+	Snippets models.SnippetRepository
+}
+
+// Fixtures is a set of users and the snippets they own.
+type Fixtures struct {
+	Users    []User    `json:"users"`
+	Snippets []Snippet `json:"snippets"`
+}
+
+// User is a user to create, with the password they log in with.
+type User struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Snippet is a snippet to create, owned by the user with the email address Owner, which must be one of the
+// fixtures' users. Titles should be unique within a set of fixtures, as Loaded looks snippets up by title.
+type Snippet struct {
+	Owner   string `json:"owner"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	// Language is the alias of the language used for syntax highlighting, or empty for plain text.
+	Language string `json:"language"`
+	// Visibility is public, unlisted or private, and defaults to public.
+	Visibility string `json:"visibility"`
+	// Expires is a lifetime such as "7d" or "never" (see the expiry package), and defaults to one year. Seeded
+	// snippets which have expired are created again the next time the fixtures are loaded.
+	Expires          string `json:"expires"`
+	BurnAfterReading bool   `json:"burn_after_reading"`
+	// Tags are lower cased and deduplicated, and must be valid tags for the create snippet form.
+	Tags []string `json:"tags"`
+}
+
+// Decode reads fixtures written as JSON, with the same field names as the API.
+func Decode(r io.Reader) (Fixtures, error) {
+	var f Fixtures
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&f)
+	return f, err
+}
+
+// Loaded is the result of loading fixtures: the ID of every fixture user and snippet, whether or not it was created by
+// this load, and how many of each were created.
+type Loaded struct {
+	// Users maps email addresses to user IDs.
+	Users map[string]int
+	// Snippets maps titles to snippet IDs.
+	Snippets        map[string]int
+	UsersCreated    int
+	SnippetsCreated int
+}
+
+// Load creates any of the fixtures' users and snippets which don't exist yet. A snippet is created along with its
+// first revision and its tags, as it would be by the create snippet form.
+func Load(r Repositories, f Fixtures) (*Loaded, error) {
+	loaded := &Loaded{Users: map[string]int{}, Snippets: map[string]int{}}
+
+	for _, u := range f.Users {
+		err := r.Users.Insert(u.Name, u.Email, u.Password)
+		if err == nil {
+			loaded.UsersCreated++
+		} else if !errors.Is(err, models.ErrDuplicateEmail) {
+			return nil, fmt.Errorf("seed: user %s: %w", u.Email, err)
+		}
+
+		id, err := r.Users.Authenticate(u.Email, u.Password)
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			return nil, fmt.Errorf("seed: user %s already exists with a different password", u.Email)
+		} else if errors.Is(err, models.ErrInactiveUser) {
+			return nil, fmt.Errorf("seed: user %s already exists and has been deactivated", u.Email)
+		} else if err != nil {
+			return nil, fmt.Errorf("seed: user %s: %w", u.Email, err)
+		}
+		loaded.Users[u.Email] = id
+	}
+
+	// existing maps the titles of each owner's live snippets to their IDs, and is filled in the first time a snippet
+	// of theirs is loaded.
+	existing := map[int]map[string]int{}
+
+	for _, s := range f.Snippets {
+		userID, ok := loaded.Users[s.Owner]
+		if !ok {
+			return nil, fmt.Errorf("seed: snippet %q is owned by %s, who isn't one of the fixtures' users", s.Title,
+				s.Owner)
+		}
+
+		if existing[userID] == nil {
+			titles, err := snippetTitles(r.Snippets, userID)
+			if err != nil {
+				return nil, err
+			}
+			existing[userID] = titles
+		}
+
+		if id, ok := existing[userID][s.Title]; ok {
+			loaded.Snippets[s.Title] = id
+			continue
+		}
+
+		id, err := insertSnippet(r, s, userID)
+		if err != nil {
+			return nil, fmt.Errorf("seed: snippet %q: %w", s.Title, err)
+		}
+		existing[userID][s.Title] = id
+		loaded.Snippets[s.Title] = id
+		loaded.SnippetsCreated++
+	}
+
+	return loaded, nil
+}
+
+// snippetTitles returns the titles of the given user's live snippets, mapped to their IDs.
+func snippetTitles(snippets models.SnippetRepository, userID int) (map[string]int, error) {
+	titles := map[string]int{}
+	for page := 1; ; page++ {
+		list, metadata, err := snippets.GetByUser(userID, page, models.MaxPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range list {
+			titles[s.Title] = s.ID
+		}
+		if !metadata.HasNext() {
+			return titles, nil
+		}
+	}
+}
+
+// insertSnippet creates a snippet owned by userID, along with its first revision and tags, and returns its ID.
+func insertSnippet(r Repositories, s Snippet, userID int) (int, error) {
+	lifetime := s.Expires
+	if lifetime == "" {
+		lifetime = "1y"
+	}
+	d, never, err := expiry.Parse(lifetime)
+	if err != nil {
+		return 0, err
+	}
+	expires := models.NeverExpires
+	if !never {
+		expires = time.Now().Add(d)
+	}
+
+	visibility := s.Visibility
+	if visibility == "" {
+		visibility = models.VisibilityPublic
+	}
+
+	// The tags are checked in the same way as those typed into the create snippet form.
+	tags := validator.NormalizeTags(s.Tags)
+	if !validator.MaxItems(tags, validator.MaxTags) {
+		return 0, fmt.Errorf("more than %d tags", validator.MaxTags)
+	}
+	for _, tag := range tags {
+		if !validator.Matches(tag, validator.TagRX) {
+			return 0, fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+
+	return r.Snippets.Insert(s.Title, s.Content, s.Language, visibility, expires, userID, s.BurnAfterReading, tags)
+}
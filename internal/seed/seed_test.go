@@ -0,0 +1,190 @@
+package seed
+
+import (
+	"github.com/rlr524/snippetboxv2/internal/models"
+	"github.com/rlr524/snippetboxv2/internal/models/mocks"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newRepositories returns repositories backed by an empty in-memory store.
+func newRepositories() (Repositories, *mocks.Store) {
+	store := mocks.NewStore()
+	return Repositories{Users: store.Users, Snippets: store.Snippets}, store
+}
+
+func TestLoadDemo(t *testing.T) {
+	r, store := newRepositories()
+	n := len(demoSnippets) + 3
+
+	loaded, err := Load(r, Demo(n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.UsersCreated != len(demoUsers) || loaded.SnippetsCreated != n {
+		t.Errorf("got %d users and %d snippets created; want %d and %d", loaded.UsersCreated,
+			loaded.SnippetsCreated, len(demoUsers), n)
+	}
+
+	// The demo users can log in with the demo password.
+	for _, u := range demoUsers {
+		id, err := store.Users.Authenticate(u.Email, DemoPassword)
+		if err != nil {
+			t.Fatalf("%s: %v", u.Email, err)
+		}
+		if id != loaded.Users[u.Email] {
+			t.Errorf("%s: got ID %d; want %d", u.Email, loaded.Users[u.Email], id)
+		}
+	}
+
+	// Every snippet has its first revision and tags, and the repeated titles are numbered.
+	for title, id := range loaded.Snippets {
+		if _, err := store.Revisions.Get(id, 1); err != nil {
+			t.Errorf("%q: no first revision: %v", title, err)
+		}
+	}
+	if _, ok := loaded.Snippets[demoSnippets[0].Title+" (2)"]; !ok {
+		t.Errorf("no repeat of %q", demoSnippets[0].Title)
+	}
+	tags, err := store.Tags.GetForSnippets([]int{loaded.Snippets[demoSnippets[0].Title]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tags[loaded.Snippets[demoSnippets[0].Title]]; len(got) != len(demoSnippets[0].Tags) {
+		t.Errorf("got tags %v; want %v", got, demoSnippets[0].Tags)
+	}
+
+	// Loading the same fixtures again creates nothing and finds the same IDs.
+	again, err := Load(r, Demo(n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.UsersCreated != 0 || again.SnippetsCreated != 0 {
+		t.Errorf("got %d users and %d snippets created again; want none", again.UsersCreated, again.SnippetsCreated)
+	}
+	for title, id := range loaded.Snippets {
+		if again.Snippets[title] != id {
+			t.Errorf("%q: got ID %d; want %d", title, again.Snippets[title], id)
+		}
+	}
+
+	// Asking for more snippets only adds the extra ones.
+	more, err := Load(r, Demo(n+2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if more.SnippetsCreated != 2 {
+		t.Errorf("got %d snippets created; want 2", more.SnippetsCreated)
+	}
+}
+
+func TestLoadFixtures(t *testing.T) {
+	f, err := Decode(strings.NewReader(`{
+		"users": [{"name": "Dana", "email": "dana@example.com", "password": "pa$$word"}],
+		"snippets": [
+			{"owner": "dana@example.com", "title": "Hello", "content": "fmt.Println(\"hello\")", "language": "go",
+				"expires": "never", "tags": ["Go", "go fmt"]},
+			{"owner": "dana@example.com", "title": "Secret", "content": "shh", "visibility": "private"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, store := newRepositories()
+	loaded, err := Load(r, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hello, err := store.Snippets.Get(loaded.Snippets["Hello"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hello.NeverExpires() || hello.Visibility != models.VisibilityPublic || hello.Language != "go" {
+		t.Errorf("got expires %v, visibility %q and language %q; want never, public and go", hello.Expires,
+			hello.Visibility, hello.Language)
+	}
+
+	// The tags are normalized as they are by the create snippet form.
+	tags, err := store.Tags.GetForSnippets([]int{hello.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tags[hello.ID]; !slices.Equal(got, []string{"fmt", "go"}) {
+		t.Errorf("got tags %v; want [fmt go]", got)
+	}
+
+	secret, err := store.Snippets.Get(loaded.Snippets["Secret"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Visibility != models.VisibilityPrivate || secret.NeverExpires() {
+		t.Errorf("got visibility %q and expires %v; want private and a year", secret.Visibility, secret.Expires)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixtures Fixtures
+		want     string
+	}{
+		{
+			name: "unknown owner",
+			fixtures: Fixtures{Snippets: []Snippet{
+				{Owner: "nobody@example.com", Title: "Orphan", Content: "..."},
+			}},
+			want: "isn't one of the fixtures' users",
+		},
+		{
+			name: "different password",
+			fixtures: Fixtures{Users: []User{
+				{Name: "Alice", Email: "alice@example.com", Password: "another password"},
+			}},
+			want: "different password",
+		},
+		{
+			name: "invalid lifetime",
+			fixtures: Fixtures{Users: demoUsers, Snippets: []Snippet{
+				{Owner: "alice@example.com", Title: "Soon", Content: "...", Expires: "soon"},
+			}},
+			want: "invalid lifetime",
+		},
+		{
+			name: "invalid tag",
+			fixtures: Fixtures{Users: demoUsers, Snippets: []Snippet{
+				{Owner: "alice@example.com", Title: "Tagged", Content: "...", Tags: []string{"go", "-flag"}},
+			}},
+			want: `snippet "Tagged": invalid tag "-flag"`,
+		},
+		{
+			name: "too many tags",
+			fixtures: Fixtures{Users: demoUsers, Snippets: []Snippet{
+				{Owner: "alice@example.com", Title: "Tagged", Content: "...", Tags: []string{"a", "b", "c", "d", "e", "f"}},
+			}},
+			want: `snippet "Tagged": more than 5 tags`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newRepositories()
+			_, err := Load(r, Fixtures{Users: demoUsers})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Load(r, tt.fixtures)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v; want one containing %q", err, tt.want)
+			}
+		})
+	}
+
+	_, err := Decode(strings.NewReader(`{"users": [], "snippet": []}`))
+	if err == nil {
+		t.Error("got no error decoding an unknown field")
+	}
+}
@@ -3,6 +3,7 @@ package validator
 import (
 	"regexp"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -60,6 +61,16 @@ func PermittedInt(value int, permittedValues ...int) bool {
 	return false
 }
 
+// PermittedValue returns true if a value is in a list of permitted values.
+func PermittedValue[T comparable](value T, permittedValues ...T) bool {
+	for i := range permittedValues {
+		if value == permittedValues[i] {
+			return true
+		}
+	}
+	return false
+}
+
 // EmailRX will Use the regexp.MustCompile() function to parse a regular expression pattern  for sanity checking the
 // format of an email address. This returns a pointer to a 'compiled' regexp.Regexp type, or panics in the
 // event of an error. Parsing this pattern once at startup and storing the compiled *regexp.Regexp in a
@@ -75,3 +86,44 @@ func MinChars(value string, n int) bool {
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// MaxItems returns true if a slice contains no more than n items.
+func MaxItems[T any](values []T, n int) bool {
+	return len(values) <= n
+}
+
+// AllMatch returns true if every value in a slice matches the provided compiled regex pattern.
+func AllMatch(values []string, rx *regexp.Regexp) bool {
+	for _, value := range values {
+		if !rx.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// TagRX matches a valid tag: 1 to 30 lower case letters, digits and the characters + # . - which appear in the
+// names of languages such as c++, c# and node.js. A tag must start with a letter or digit.
+var TagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9+#.-]{0,29}$`)
+
+// MaxTags is the maximum number of tags which can be added to a snippet.
+const MaxTags = 5
+
+// NormalizeTags splits tags on commas and whitespace, so that the HTML form can send them as a single field, and
+// lower cases and removes duplicates so that "Go" and "go" are the same tag. The result should then be checked
+// against MaxTags and TagRX.
+func NormalizeTags(values []string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+
+	for _, value := range values {
+		for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			tag = strings.ToLower(tag)
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
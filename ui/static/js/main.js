@@ -5,4 +5,49 @@ for (let i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// Highlight the lines of a snippet named in the URL fragment, which is either a single line such as #L10 or a range
+// such as #L10-L20. Shift-clicking a line number extends the current line into a range.
+function highlightLines() {
+	let match = window.location.hash.match(/^#L(\d+)(?:-L(\d+))?$/);
+	let numbers = document.querySelectorAll(".chroma .lnt");
+	let lines = document.querySelectorAll(".chroma .line");
+
+	for (let i = 0; i < lines.length; i++) {
+		lines[i].classList.remove("hl");
+	}
+	if (!match) {
+		return;
+	}
+
+	let start = parseInt(match[1], 10);
+	let end = match[2] ? parseInt(match[2], 10) : start;
+	// Lines are numbered from 1, and the line links always write the lower number first.
+	if (start < 1 || start > end) {
+		return;
+	}
+	for (let i = start; i <= end && i <= lines.length; i++) {
+		lines[i - 1].classList.add("hl");
+	}
+
+	if (numbers.length >= start) {
+		numbers[start - 1].scrollIntoView({block: "center"});
+	}
+}
+
+let lineLinks = document.querySelectorAll(".chroma a.lnlinks");
+for (let i = 0; i < lineLinks.length; i++) {
+	lineLinks[i].addEventListener("click", function (event) {
+		let current = window.location.hash.match(/^#L(\d+)/);
+		if (event.shiftKey && current) {
+			event.preventDefault();
+			let from = parseInt(current[1], 10);
+			let to = i + 1;
+			window.location.hash = "#L" + Math.min(from, to) + "-L" + Math.max(from, to);
+		}
+	});
+}
+
+window.addEventListener("hashchange", highlightLines);
+highlightLines();